@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// Status is the connection status reported by the API
+type Status struct {
+	State       string     `json:"state"`
+	Message     string     `json:"message"`
+	Adapter     string     `json:"adapter"`
+	SSID        string     `json:"ssid"`
+	Signal      string     `json:"signal"`
+	Target      string     `json:"target"`
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+}
+
+// Controller is the connection manager driven by the API. The tray menu
+// uses the same operations.
+type Controller interface {
+	Status() Status
+	ScanNetworks() ([]wifi.Network, error)
+	SavedProfiles() ([]string, error)
+	Connect() error
+	Disconnect() error
+	Pause(d time.Duration)
+	Resume()
+	Config() config.Config
+	UpdateConfig(cfg config.Config) error
+}
+
+// Server serves the JSON control API on localhost
+type Server struct {
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+// NewHandler returns the API handler, requiring token on every request
+func NewHandler(ctrl Controller, token string) http.Handler {
+	h := &handler{ctrl: ctrl}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", h.method(http.MethodGet, h.status))
+	mux.HandleFunc("/api/networks", h.method(http.MethodGet, h.networks))
+	mux.HandleFunc("/api/profiles", h.method(http.MethodGet, h.profiles))
+	mux.HandleFunc("/api/connect", h.method(http.MethodPost, h.connect))
+	mux.HandleFunc("/api/disconnect", h.method(http.MethodPost, h.disconnect))
+	mux.HandleFunc("/api/pause", h.method(http.MethodPost, h.pause))
+	mux.HandleFunc("/api/resume", h.method(http.MethodPost, h.resume))
+	mux.HandleFunc("/api/config", h.config)
+
+	return requireToken(token, mux)
+}
+
+// Start serves the API on 127.0.0.1 at port
+func Start(port int, ctrl Controller, token string) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		httpServer: &http.Server{
+			Handler:           NewHandler(ctrl, token),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		listener: listener,
+	}
+	go s.httpServer.Serve(listener)
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// requireToken rejects requests without a matching bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type handler struct {
+	ctrl Controller
+}
+
+// method restricts a handler to a single HTTP method
+func (h *handler) method(method string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use %s", method))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.Status())
+}
+
+func (h *handler) networks(w http.ResponseWriter, r *http.Request) {
+	networks, err := h.ctrl.ScanNetworks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if networks == nil {
+		networks = []wifi.Network{}
+	}
+	writeJSON(w, http.StatusOK, networks)
+}
+
+func (h *handler) profiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.ctrl.SavedProfiles()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if profiles == nil {
+		profiles = []string{}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+func (h *handler) connect(w http.ResponseWriter, r *http.Request) {
+	if err := h.ctrl.Connect(); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.ctrl.Status())
+}
+
+func (h *handler) disconnect(w http.ResponseWriter, r *http.Request) {
+	if err := h.ctrl.Disconnect(); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.ctrl.Status())
+}
+
+// pauseRequest is the optional body of /api/pause. Zero minutes pauses
+// until resumed.
+type pauseRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+func (h *handler) pause(w http.ResponseWriter, r *http.Request) {
+	var req pauseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if req.Minutes < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("minutes must not be negative"))
+		return
+	}
+
+	h.ctrl.Pause(time.Duration(req.Minutes) * time.Minute)
+	writeJSON(w, http.StatusOK, h.ctrl.Status())
+}
+
+func (h *handler) resume(w http.ResponseWriter, r *http.Request) {
+	h.ctrl.Resume()
+	writeJSON(w, http.StatusOK, h.ctrl.Status())
+}
+
+// config returns the config on GET and applies the fields in the body on
+// PATCH, leaving the others unchanged. Secrets are masked in responses.
+func (h *handler) config(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, maskSecrets(h.ctrl.Config()))
+
+	case http.MethodPatch:
+		current := h.ctrl.Config()
+		cfg := *current.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		keepSecrets(&cfg, current)
+		if err := h.ctrl.UpdateConfig(cfg); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, maskSecrets(h.ctrl.Config()))
+
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use GET or PATCH"))
+	}
+}
+
+// secretMask replaces secrets in config responses. Sending it back in a
+// PATCH keeps the stored secret.
+const secretMask = "********"
+
+// maskSecrets returns cfg with its passwords, PIN and webhook secrets masked
+func maskSecrets(cfg config.Config) config.Config {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return secretMask
+	}
+	cfg.MQTTPassword = mask(cfg.MQTTPassword)
+	cfg.DashboardPIN = mask(cfg.DashboardPIN)
+	webhooks := make([]config.WebhookTarget, len(cfg.Webhooks))
+	for i, target := range cfg.Webhooks {
+		webhooks[i] = config.WebhookTarget{URL: target.URL, Secret: mask(target.Secret)}
+	}
+	cfg.Webhooks = webhooks
+	return cfg
+}
+
+// keepSecrets restores the secrets of current that cfg still has masked
+func keepSecrets(cfg *config.Config, current config.Config) {
+	if cfg.MQTTPassword == secretMask {
+		cfg.MQTTPassword = current.MQTTPassword
+	}
+	if cfg.DashboardPIN == secretMask {
+		cfg.DashboardPIN = current.DashboardPIN
+	}
+	webhooks := make([]config.WebhookTarget, len(cfg.Webhooks))
+	for i, target := range cfg.Webhooks {
+		if target.Secret == secretMask {
+			target.Secret = ""
+			for _, old := range current.Webhooks {
+				if old.URL == target.URL {
+					target.Secret = old.Secret
+					break
+				}
+			}
+		}
+		webhooks[i] = target
+	}
+	cfg.Webhooks = webhooks
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+const testToken = "test-token"
+
+// fakeController records the calls made through the API
+type fakeController struct {
+	status     Status
+	cfg        config.Config
+	connectErr error
+	calls      []string
+}
+
+func newFakeController() *fakeController {
+	cfg := config.DefaultConfig()
+	cfg.SelectedNetwork = "Quadmax"
+	cfg.MQTTPassword = "mqtt-pass"
+	cfg.DashboardPIN = "1234"
+	cfg.Webhooks = []config.WebhookTarget{{URL: "https://example.com/hook", Secret: "hook-secret"}}
+	return &fakeController{
+		status: Status{State: "Connected", SSID: "Quadmax", Signal: "85%", Target: "Quadmax"},
+		cfg:    *cfg,
+	}
+}
+
+func (f *fakeController) Status() Status                        { return f.status }
+func (f *fakeController) ScanNetworks() ([]wifi.Network, error) { return nil, nil }
+func (f *fakeController) SavedProfiles() ([]string, error)      { return []string{"Quadmax"}, nil }
+func (f *fakeController) Pause(d time.Duration)                 { f.calls = append(f.calls, "pause") }
+func (f *fakeController) Resume()                               { f.calls = append(f.calls, "resume") }
+func (f *fakeController) Config() config.Config                 { return *f.cfg.Clone() }
+
+func (f *fakeController) Connect() error {
+	f.calls = append(f.calls, "connect")
+	if f.connectErr != nil {
+		return f.connectErr
+	}
+	f.status.State = "Connected"
+	return nil
+}
+
+func (f *fakeController) Disconnect() error {
+	f.calls = append(f.calls, "disconnect")
+	f.status.State = "Disconnected"
+	return nil
+}
+
+func (f *fakeController) UpdateConfig(cfg config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	f.cfg = cfg
+	return nil
+}
+
+// do sends a request with the test token to a handler for ctrl
+func do(t *testing.T, ctrl Controller, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	NewHandler(ctrl, testToken).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireToken(t *testing.T) {
+	handler := NewHandler(newFakeController(), testToken)
+	for _, auth := range []string{"", "Bearer wrong", testToken, "Bearer " + testToken + "x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, rec.Code)
+		}
+	}
+
+	// An empty token must never match
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	NewHandler(newFakeController(), "").ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	rec := do(t, newFakeController(), http.MethodGet, "/api/status", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != "Connected" || status.SSID != "Quadmax" || status.Signal != "85%" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if rec := do(t, newFakeController(), http.MethodPost, "/api/status", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/status: status %d, want 405", rec.Code)
+	}
+}
+
+func TestConnectDisconnect(t *testing.T) {
+	ctrl := newFakeController()
+
+	rec := do(t, ctrl, http.MethodPost, "/api/disconnect", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"state":"Disconnected"`) {
+		t.Errorf("disconnect: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(t, ctrl, http.MethodPost, "/api/connect", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"state":"Connected"`) {
+		t.Errorf("connect: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, ctrl, http.MethodGet, "/api/connect", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/connect: status %d, want 405", rec.Code)
+	}
+	if got := strings.Join(ctrl.calls, ","); got != "disconnect,connect" {
+		t.Errorf("calls = %s, want disconnect,connect", got)
+	}
+
+	ctrl.connectErr = errors.New("network not in range")
+	rec = do(t, ctrl, http.MethodPost, "/api/connect", "")
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "not in range") {
+		t.Errorf("failed connect: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPause(t *testing.T) {
+	ctrl := newFakeController()
+	if rec := do(t, ctrl, http.MethodPost, "/api/pause", `{"minutes":-1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative pause: status %d, want 400", rec.Code)
+	}
+	if rec := do(t, ctrl, http.MethodPost, "/api/pause", `{"minutes":30}`); rec.Code != http.StatusOK {
+		t.Errorf("pause: status %d: %s", rec.Code, rec.Body)
+	}
+	if len(ctrl.calls) != 1 || ctrl.calls[0] != "pause" {
+		t.Errorf("calls = %v, want one pause", ctrl.calls)
+	}
+}
+
+func TestGetConfigMasksSecrets(t *testing.T) {
+	rec := do(t, newFakeController(), http.MethodGet, "/api/config", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	for _, secret := range []string{"mqtt-pass", "1234", "hook-secret"} {
+		if strings.Contains(rec.Body.String(), secret) {
+			t.Errorf("response contains secret %q", secret)
+		}
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(rec.Body.Bytes(), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MQTTPassword != secretMask || cfg.Webhooks[0].Secret != secretMask || cfg.Webhooks[0].URL != "https://example.com/hook" {
+		t.Errorf("secrets not masked: %+v", cfg)
+	}
+}
+
+func TestPatchConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		assert func(t *testing.T, cfg config.Config)
+	}{
+		{
+			name: "partial update",
+			body: `{"poll_interval":20}`,
+			code: http.StatusOK,
+			assert: func(t *testing.T, cfg config.Config) {
+				if cfg.PollInterval != 20 || cfg.SelectedNetwork != "Quadmax" {
+					t.Errorf("got poll interval %d, target %q", cfg.PollInterval, cfg.SelectedNetwork)
+				}
+			},
+		},
+		{
+			name: "invalid value",
+			body: `{"poll_interval":0}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "malformed body",
+			body: `{"poll_interval":`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "masked secrets are kept",
+			body: `{"mqtt_password":"********","dashboard_pin":"********","webhooks":[{"url":"https://example.com/hook","secret":"********"}]}`,
+			code: http.StatusOK,
+			assert: func(t *testing.T, cfg config.Config) {
+				if cfg.MQTTPassword != "mqtt-pass" || cfg.DashboardPIN != "1234" || cfg.Webhooks[0].Secret != "hook-secret" {
+					t.Errorf("secrets changed: %q %q %+v", cfg.MQTTPassword, cfg.DashboardPIN, cfg.Webhooks)
+				}
+			},
+		},
+		{
+			name: "secrets can be replaced",
+			body: `{"mqtt_password":"new-pass","webhooks":[{"url":"https://example.com/other","secret":"********"}]}`,
+			code: http.StatusOK,
+			assert: func(t *testing.T, cfg config.Config) {
+				if cfg.MQTTPassword != "new-pass" {
+					t.Errorf("MQTT password = %q, want new-pass", cfg.MQTTPassword)
+				}
+				if cfg.Webhooks[0].Secret != "" {
+					t.Errorf("masked secret of a new webhook URL = %q, want empty", cfg.Webhooks[0].Secret)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := newFakeController()
+			before := ctrl.Config()
+			rec := do(t, ctrl, http.MethodPatch, "/api/config", tt.body)
+			if rec.Code != tt.code {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				if ctrl.cfg.PollInterval != before.PollInterval {
+					t.Errorf("rejected update changed the config")
+				}
+				return
+			}
+			if strings.Contains(rec.Body.String(), "mqtt-pass") {
+				t.Errorf("response contains the MQTT password")
+			}
+			tt.assert(t, ctrl.cfg)
+		})
+	}
+}
@@ -0,0 +1,4 @@
+package autostart
+
+// name identifies the app's autostart entry
+const name = "Quadmax WiFi Connector"
@@ -0,0 +1,60 @@
+//go:build !windows
+
+package autostart
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// desktopFile returns the path of the XDG autostart entry
+func desktopFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "autostart", "quadmax-wifi-connector.desktop"), nil
+}
+
+// Enabled reports whether the app starts at login
+func Enabled() (bool, error) {
+	path, err := desktopFile()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Enable starts the app at login
+func Enable() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	path, err := desktopFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=%s\nExec=%q\nX-GNOME-Autostart-enabled=true\n", name, exe)
+	return os.WriteFile(path, []byte(entry), 0644)
+}
+
+// Disable stops the app from starting at login
+func Disable() error {
+	path, err := desktopFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
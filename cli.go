@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/daemon"
+	"github.com/whenry/quadmax-wifi-connector/history"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// Exit codes for CLI subcommands
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitNotConnected = 3
+)
+
+// cliCommand is a headless subcommand that runs instead of the tray
+type cliCommand struct {
+	usage string
+	run   func(c *cliContext, args []string) int
+}
+
+var cliCommands = map[string]cliCommand{
+	"status":      {"status [--adapter NAME]", runStatus},
+	"adapters":    {"adapters", runAdapters},
+	"scan":        {"scan [--adapter NAME]", runScan},
+	"profiles":    {"profiles", runProfiles},
+	"connect":     {"connect [--adapter NAME] <ssid>", runConnect},
+	"disconnect":  {"disconnect [--adapter NAME]", runDisconnect},
+	"watch":       {"watch [--adapter NAME] [--interval SECONDS]", runWatch},
+	"unit-file":   {"unit-file [--user NAME]", runUnitFile},
+	"history":     {"history [--days N] [--stats | --csv]", runHistory},
+	"diagnostics": {"diagnostics [--output PATH]", runDiagnostics},
+	"version":     {"version", runVersion},
+}
+
+// cliContext holds the options shared by all subcommands
+type cliContext struct {
+	flags   *flag.FlagSet
+	json    bool
+	adapter string
+	out     io.Writer
+}
+
+// isCLICommand reports whether arg names a subcommand
+func isCLICommand(arg string) bool {
+	if arg == "help" {
+		return true
+	}
+	_, ok := cliCommands[arg]
+	return ok
+}
+
+// runCLI runs a subcommand and returns its exit code
+func runCLI(name string, args []string) int {
+	attachConsole()
+
+	command, ok := cliCommands[name]
+	if !ok {
+		printUsage(os.Stdout)
+		if name == "help" {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	// Default to the adapter from the config, like the tray does
+	defaultAdapter := ""
+	if loaded, err := config.Load(); err == nil {
+		defaultAdapter = loaded.SelectedAdapter
+	}
+
+	c := &cliContext{out: os.Stdout}
+	c.flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	c.flags.SetOutput(os.Stderr)
+	c.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: quadmax-wifi-connector %s [--json]\n", command.usage)
+	}
+	c.flags.BoolVar(&c.json, "json", false, "print JSON output")
+	c.flags.StringVar(&c.adapter, "adapter", defaultAdapter, "wireless adapter to use")
+	return command.run(c, args)
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: quadmax-wifi-connector [--settings] [--connect] [--daemon]")
+	fmt.Fprintln(w, "       quadmax-wifi-connector <command> [--json]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", cliCommands[name].usage)
+	}
+}
+
+// parse parses the subcommand flags and checks the positional argument count
+func (c *cliContext) parse(args []string, positional int) bool {
+	if err := c.flags.Parse(args); err != nil {
+		return false
+	}
+	if c.flags.NArg() != positional {
+		c.flags.Usage()
+		return false
+	}
+	return true
+}
+
+// print writes v as JSON or as the human-readable text
+func (c *cliContext) print(v interface{}, text string) {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+		return
+	}
+	fmt.Fprintln(c.out, text)
+}
+
+func (c *cliContext) fail(err error) int {
+	if c.json {
+		c.print(map[string]string{"error": err.Error()}, "")
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return exitError
+}
+
+func describeStatus(status *wifi.ConnectionStatus) string {
+	if !status.Connected {
+		return fmt.Sprintf("%s: not connected", status.AdapterName)
+	}
+	return fmt.Sprintf("%s: connected to %s (signal %s)", status.AdapterName, status.SSID, status.SignalStrength)
+}
+
+func runStatus(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	status, err := wifi.GetConnectionStatus(c.adapter)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	c.print(status, describeStatus(status))
+	if !status.Connected {
+		return exitNotConnected
+	}
+	return exitOK
+}
+
+func runAdapters(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	adapters, err := wifi.GetAdapters()
+	if err != nil {
+		return c.fail(err)
+	}
+
+	lines := []string{}
+	for _, a := range adapters {
+		lines = append(lines, fmt.Sprintf("%s\t%s", a.Name, a.State))
+	}
+	c.print(adapters, strings.Join(lines, "\n"))
+	return exitOK
+}
+
+func runScan(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	networks, err := wifi.ScanNetworks(c.adapter)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	lines := []string{}
+	for _, n := range networks {
+		lines = append(lines, n.SSID)
+	}
+	c.print(networks, strings.Join(lines, "\n"))
+	return exitOK
+}
+
+func runProfiles(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	profiles, err := wifi.GetSavedProfiles()
+	if err != nil {
+		return c.fail(err)
+	}
+
+	c.print(profiles, strings.Join(profiles, "\n"))
+	return exitOK
+}
+
+func runConnect(c *cliContext, args []string) int {
+	if !c.parse(args, 1) {
+		return exitUsage
+	}
+	ssid := c.flags.Arg(0)
+
+	if err := wifi.Connect(c.adapter, ssid); err != nil {
+		return c.fail(fmt.Errorf("could not connect to %s: %w", ssid, err))
+	}
+
+	// Wait a moment and verify connection
+	time.Sleep(2 * time.Second)
+
+	status, err := wifi.GetConnectionStatus(c.adapter)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	c.print(status, describeStatus(status))
+	if !status.Connected || status.SSID != ssid {
+		return exitNotConnected
+	}
+	return exitOK
+}
+
+func runDisconnect(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	if err := wifi.Disconnect(c.adapter); err != nil {
+		return c.fail(err)
+	}
+
+	status, err := wifi.GetConnectionStatus(c.adapter)
+	if err != nil {
+		return c.fail(err)
+	}
+	c.print(status, describeStatus(status))
+	return exitOK
+}
+
+// runWatch prints the connection status whenever it changes until
+// interrupted
+func runWatch(c *cliContext, args []string) int {
+	interval := c.flags.Int("interval", 5, "seconds between checks")
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+	if *interval <= 0 {
+		c.flags.Usage()
+		return exitUsage
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(time.Duration(*interval) * time.Second)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		status, err := wifi.GetConnectionStatus(c.adapter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		} else if text := describeStatus(status); text != last {
+			last = text
+			if c.json {
+				// One object per line so the output can be streamed
+				json.NewEncoder(c.out).Encode(struct {
+					Time time.Time `json:"time"`
+					*wifi.ConnectionStatus
+				}{time.Now(), status})
+			} else {
+				fmt.Fprintf(c.out, "%s %s\n", time.Now().Format("15:04:05"), text)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			return exitOK
+		}
+	}
+}
+
+// runUnitFile prints a systemd unit that runs this executable in daemon mode
+func runUnitFile(c *cliContext, args []string) int {
+	user := c.flags.String("user", "", "user to run the service as")
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return c.fail(err)
+	}
+
+	unit, err := daemon.UnitFile(daemon.UnitOptions{ExecPath: execPath, User: *user})
+	if err != nil {
+		return c.fail(err)
+	}
+
+	c.print(map[string]string{"unit": unit}, strings.TrimRight(unit, "\n"))
+	return exitOK
+}
+
+// runHistory prints the recorded connection history, its statistics, or
+// the history as CSV
+func runHistory(c *cliContext, args []string) int {
+	days := c.flags.Int("days", 7, "number of days to include")
+	showStats := c.flags.Bool("stats", false, "print uptime statistics")
+	asCSV := c.flags.Bool("csv", false, "print the history as CSV")
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+	if *days <= 0 || (*showStats && *asCSV) {
+		c.flags.Usage()
+		return exitUsage
+	}
+
+	store, err := history.OpenDefault()
+	if err != nil {
+		return c.fail(err)
+	}
+	now := time.Now()
+	from := now.AddDate(0, 0, -*days)
+	entries, err := store.Load(time.Time{})
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if *showStats {
+		stats := history.Compute(entries, from, now)
+		c.print(stats, describeStats(stats))
+		return exitOK
+	}
+
+	// Only list entries in the period, stats use earlier ones for context
+	var recent []history.Entry
+	for _, e := range history.Transitions(entries) {
+		if !e.Time.Before(from) {
+			recent = append(recent, e)
+		}
+	}
+
+	if *asCSV {
+		if err := history.WriteCSV(c.out, recent); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+
+	lines := []string{}
+	for _, e := range recent {
+		line := fmt.Sprintf("%s  %-12s  %s", e.Time.Format("2006-01-02 15:04:05"), e.State, e.Reason)
+		if e.SSID != "" {
+			line += fmt.Sprintf(" [%s %s %d%%]", e.SSID, e.BSSID, e.Signal)
+		}
+		lines = append(lines, line)
+	}
+	if recent == nil {
+		recent = []history.Entry{}
+	}
+	c.print(recent, strings.Join(lines, "\n"))
+	return exitOK
+}
+
+func describeStats(stats history.Stats) string {
+	lines := []string{
+		fmt.Sprintf("Uptime:                  %.1f%%", stats.Uptime()),
+		fmt.Sprintf("Drops:                   %d", stats.Drops),
+	}
+	if stats.Drops > 0 {
+		lines = append(lines, fmt.Sprintf("Mean time between drops: %s", stats.MeanTimeBetweenDrops.Round(time.Second)))
+	}
+	if len(stats.Reconnects) > 0 {
+		lines = append(lines,
+			fmt.Sprintf("Mean reconnect time:     %s", stats.MeanReconnect.Round(time.Second)),
+			fmt.Sprintf("Longest reconnect time:  %s", stats.MaxReconnect.Round(time.Second)),
+		)
+	}
+	lines = append(lines, "", "Daily uptime:")
+	for _, day := range stats.Days {
+		lines = append(lines, fmt.Sprintf("  %s  %5.1f%%", day.Date.Format("2006-01-02"), day.Uptime()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runDiagnostics writes a diagnostics bundle for support
+func runDiagnostics(c *cliContext, args []string) int {
+	output := c.flags.String("output", "", "path of the zip file to write")
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+
+	loaded, err := config.Load()
+	if err != nil {
+		return c.fail(err)
+	}
+	cfg = loaded
+
+	path, err := createDiagnostics(*output)
+	if err != nil {
+		return c.fail(err)
+	}
+	c.print(map[string]string{"path": path}, "Diagnostics bundle written to "+path)
+	return exitOK
+}
+
+func runVersion(c *cliContext, args []string) int {
+	if !c.parse(args, 0) {
+		return exitUsage
+	}
+	c.print(map[string]string{"version": version}, version)
+	return exitOK
+}
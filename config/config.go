@@ -2,8 +2,17 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/hooks"
 )
 
 const (
@@ -11,11 +20,105 @@ const (
 	configFile = "config.json"
 )
 
-// Config holds the persistent application configuration
+// Config holds the persistent application configuration. Fields with a
+// label tag are described by Fields and edited in the Advanced settings.
 type Config struct {
 	SelectedAdapter string `json:"selected_adapter"`
 	SelectedNetwork string `json:"selected_network"`
-	PollInterval    int    `json:"poll_interval"` // in seconds
+	PollInterval    int    `json:"poll_interval" group:"Connection" label:"Poll interval" unit:"seconds" min:"1"` // in seconds
+
+	// ConnectTimeout limits a connect command and VerifyDeadline is how long
+	// the connection then has to come up
+	ConnectTimeout int `json:"connect_timeout" group:"Connection" label:"Connect timeout" unit:"seconds" min:"1"`       // in seconds
+	VerifyDeadline int `json:"verify_deadline" group:"Connection" label:"Verification deadline" unit:"seconds" min:"1"` // in seconds
+
+	// RestorePreviousNetwork reconnects to the network the adapter was on
+	// before switching to the target once the target has been out of range
+	// for RestoreGracePeriod seconds
+	RestorePreviousNetwork bool `json:"restore_previous_network"`
+	RestoreGracePeriod     int  `json:"restore_grace_period" min:"1"` // in seconds
+
+	// DualAdapterMode dedicates SelectedAdapter to the Quadmax and keeps
+	// InternetAdapter connected to InternetNetwork
+	DualAdapterMode bool   `json:"dual_adapter_mode"`
+	InternetAdapter string `json:"internet_adapter"`
+	InternetNetwork string `json:"internet_network"`
+
+	// ControlAPIEnabled serves the JSON control API on localhost
+	ControlAPIEnabled bool `json:"control_api_enabled" group:"Integrations" label:"Control API" help:"Takes effect after restarting the app"`
+	ControlAPIPort    int  `json:"control_api_port" group:"Integrations" label:"Control API port" min:"1" max:"65535"`
+
+	// MetricsEnabled serves Prometheus metrics on localhost
+	MetricsEnabled bool `json:"metrics_enabled" group:"Integrations" label:"Prometheus metrics" help:"Takes effect after restarting the app"`
+	MetricsPort    int  `json:"metrics_port" group:"Integrations" label:"Metrics port" min:"1" max:"65535"`
+
+	// DashboardEnabled serves the web dashboard on localhost, or on all
+	// interfaces behind DashboardPIN if DashboardLAN is set
+	DashboardEnabled bool   `json:"dashboard_enabled" group:"Integrations" label:"Web dashboard" help:"Takes effect after restarting the app"`
+	DashboardPort    int    `json:"dashboard_port" group:"Integrations" label:"Dashboard port" min:"1" max:"65535"`
+	DashboardLAN     bool   `json:"dashboard_lan" group:"Integrations" label:"Dashboard LAN access"`
+	DashboardPIN     string `json:"dashboard_pin" group:"Integrations" label:"Dashboard PIN" format:"password"`
+
+	// StatusFile is continuously rewritten with the connection state as
+	// JSON, if set, and EventStreamEnabled serves it as Server-Sent Events
+	// on localhost
+	StatusFile         string `json:"status_file" group:"Integrations" label:"Status file"`
+	EventStreamEnabled bool   `json:"event_stream_enabled" group:"Integrations" label:"Event stream" help:"Takes effect after restarting the app"`
+	EventStreamPort    int    `json:"event_stream_port" group:"Integrations" label:"Event stream port" min:"1" max:"65535"`
+
+	// MQTTEnabled publishes the connection state to MQTTBroker under
+	// MQTTTopic, by default quadmax/<host name>, and announces it to Home
+	// Assistant if MQTTDiscovery is set
+	MQTTEnabled         bool   `json:"mqtt_enabled" group:"Integrations" label:"MQTT" help:"Takes effect after restarting the app"`
+	MQTTBroker          string `json:"mqtt_broker" group:"Integrations" label:"MQTT broker"`
+	MQTTUsername        string `json:"mqtt_username" group:"Integrations" label:"MQTT username"`
+	MQTTPassword        string `json:"mqtt_password" group:"Integrations" label:"MQTT password" format:"password"`
+	MQTTTopic           string `json:"mqtt_topic" group:"Integrations" label:"MQTT topic"`
+	MQTTDiscovery       bool   `json:"mqtt_discovery" group:"Integrations" label:"Home Assistant discovery"`
+	MQTTDiscoveryPrefix string `json:"mqtt_discovery_prefix" group:"Integrations" label:"Discovery prefix"`
+
+	// Notifier is auto, toast, dbus or log
+	Notifier string `json:"notifier" group:"Notifications" label:"Show notifications with" options:"auto,toast,dbus,log"`
+
+	// Per-event notification switches
+	NotifyConnected      bool `json:"notify_connected" group:"Notifications" label:"Notify when connected"`
+	NotifyDisconnected   bool `json:"notify_disconnected" group:"Notifications" label:"Notify when disconnected"`
+	NotifyFailed         bool `json:"notify_failed" group:"Notifications" label:"Notify when a connection fails"`
+	NotifyLowSignal      bool `json:"notify_low_signal" group:"Notifications" label:"Notify on low signal"`
+	NotifyAdapterMissing bool `json:"notify_adapter_missing" group:"Notifications" label:"Notify when the adapter is missing"`
+	NotifySameNetwork    bool `json:"notify_same_network" group:"Notifications" label:"Notify when both adapters share a network"`
+	NotifyRestored       bool `json:"notify_restored" group:"Notifications" label:"Notify when the previous network is restored"`
+	LowSignalThreshold   int  `json:"low_signal_threshold" group:"Notifications" label:"Low signal threshold" unit:"%" max:"100"` // in percent
+
+	// NotifyDedupWindow drops repeats of a notification and NotifyFlapWindow
+	// holds back disconnect notifications in case the connection comes back
+	NotifyDedupWindow int `json:"notify_dedup_window" group:"Notifications" label:"Skip repeats within" unit:"seconds"`      // in seconds
+	NotifyFlapWindow  int `json:"notify_flap_window" group:"Notifications" label:"Ignore drops shorter than" unit:"seconds"` // in seconds
+
+	// QuietHours mutes notifications between QuietHoursStart and
+	// QuietHoursEnd, given as HH:MM
+	QuietHours      bool   `json:"quiet_hours" group:"Notifications" label:"Quiet hours"`
+	QuietHoursStart string `json:"quiet_hours_start" group:"Notifications" label:"Quiet hours start" format:"clock"`
+	QuietHoursEnd   string `json:"quiet_hours_end" group:"Notifications" label:"Quiet hours end" format:"clock"`
+
+	// Hooks maps event names such as "connected" to a shell command run
+	// when the event happens. Commands are killed after HookTimeout, so
+	// long-running programs must be started in the background.
+	Hooks       map[string]string `json:"hooks"`
+	HookTimeout int               `json:"hook_timeout" group:"Integrations" label:"Hook timeout" unit:"seconds" min:"1"` // in seconds
+
+	// Webhooks receive a JSON payload for every connection state change
+	Webhooks []WebhookTarget `json:"webhooks"`
+
+	// LogLevel is one of debug, info, warn or error
+	LogLevel string `json:"log_level" group:"Logging" label:"Log level" options:"debug,info,warn,error"`
+}
+
+// WebhookTarget is a URL that receives connection events. Requests are
+// signed with Secret if it is set.
+type WebhookTarget struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
 }
 
 // DefaultConfig returns a config with default values
@@ -24,11 +127,112 @@ func DefaultConfig() *Config {
 		SelectedAdapter: "",
 		SelectedNetwork: "",
 		PollInterval:    5,
+
+		ConnectTimeout: 15,
+		VerifyDeadline: 10,
+
+		RestorePreviousNetwork: false,
+		RestoreGracePeriod:     30,
+
+		DualAdapterMode: false,
+		InternetAdapter: "",
+		InternetNetwork: "",
+
+		ControlAPIEnabled: false,
+		ControlAPIPort:    8731,
+
+		MetricsEnabled: false,
+		MetricsPort:    9731,
+
+		DashboardEnabled: false,
+		DashboardPort:    8734,
+		DashboardLAN:     false,
+		DashboardPIN:     "",
+
+		StatusFile:         "",
+		EventStreamEnabled: false,
+		EventStreamPort:    8732,
+
+		MQTTEnabled:         false,
+		MQTTBroker:          "",
+		MQTTTopic:           "",
+		MQTTDiscovery:       true,
+		MQTTDiscoveryPrefix: "homeassistant",
+
+		Notifier: "auto",
+
+		NotifyConnected:      true,
+		NotifyDisconnected:   true,
+		NotifyFailed:         true,
+		NotifyLowSignal:      false,
+		NotifyAdapterMissing: true,
+		NotifySameNetwork:    true,
+		NotifyRestored:       true,
+		LowSignalThreshold:   30,
+
+		NotifyDedupWindow: 300,
+		NotifyFlapWindow:  30,
+
+		QuietHours:      false,
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "07:00",
+
+		Hooks:       map[string]string{},
+		HookTimeout: 30,
+
+		LogLevel: "info",
+	}
+}
+
+// Clone returns a copy of c that shares no maps with it
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Hooks = maps.Clone(c.Hooks)
+	clone.Webhooks = slices.Clone(c.Webhooks)
+	return &clone
+}
+
+// Validate checks that the config values are usable
+func (c *Config) Validate() error {
+	for _, f := range taggedFields() {
+		if err := f.check(c); err != nil {
+			return err
+		}
 	}
+	if c.DashboardLAN && len(c.DashboardPIN) < 4 {
+		return errors.New("a dashboard PIN of at least 4 characters is required for LAN access")
+	}
+	if c.MQTTEnabled {
+		u, err := url.Parse(c.MQTTBroker)
+		if err != nil || u.Host == "" {
+			return errors.New("MQTT broker must be a URL such as tcp://host:1883")
+		}
+	}
+	if _, err := time.Parse("15:04", c.QuietHoursStart); err != nil {
+		return errors.New("quiet hours start must be HH:MM")
+	}
+	if _, err := time.Parse("15:04", c.QuietHoursEnd); err != nil {
+		return errors.New("quiet hours end must be HH:MM")
+	}
+	for event := range c.Hooks {
+		if !slices.Contains(hooks.Events, event) {
+			return fmt.Errorf("unknown hook event %q", event)
+		}
+	}
+	for _, target := range c.Webhooks {
+		u, err := url.Parse(target.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook URL %q must be an http or https URL", target.URL)
+		}
+	}
+	if c.DualAdapterMode && c.InternetAdapter != "" && c.InternetAdapter == c.SelectedAdapter {
+		return errors.New("internet adapter must differ from the Quadmax adapter")
+	}
+	return nil
 }
 
-// getConfigPath returns the path to the config file
-func getConfigPath() (string, error) {
+// Dir returns the directory holding the config file and other app data
+func Dir() (string, error) {
 	appData := os.Getenv("APPDATA")
 	if appData == "" {
 		// Fallback for non-Windows or testing
@@ -39,13 +243,32 @@ func getConfigPath() (string, error) {
 		appData = filepath.Join(homeDir, ".config")
 	}
 
-	configDir := filepath.Join(appData, appName)
+	return filepath.Join(appData, appName), nil
+}
+
+// Path returns the path to the config file
+func Path() (string, error) {
+	configDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(configDir, configFile), nil
 }
 
+// Exists reports whether a config file has been saved, which is not the case
+// until first-run setup is finished or skipped
+func Exists() bool {
+	configPath, err := Path()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(configPath)
+	return err == nil
+}
+
 // Load reads the configuration from disk
 func Load() (*Config, error) {
-	configPath, err := getConfigPath()
+	configPath, err := Path()
 	if err != nil {
 		return DefaultConfig(), err
 	}
@@ -58,8 +281,10 @@ func Load() (*Config, error) {
 		return DefaultConfig(), err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// Start from the defaults so settings missing from older config files
+	// keep their default values
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
 		return DefaultConfig(), err
 	}
 
@@ -67,13 +292,57 @@ func Load() (*Config, error) {
 	if cfg.PollInterval <= 0 {
 		cfg.PollInterval = 5
 	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = 15
+	}
+	if cfg.VerifyDeadline <= 0 {
+		cfg.VerifyDeadline = 10
+	}
+	if cfg.RestoreGracePeriod <= 0 {
+		cfg.RestoreGracePeriod = 30
+	}
+	if cfg.ControlAPIPort <= 0 {
+		cfg.ControlAPIPort = 8731
+	}
+	if cfg.MetricsPort <= 0 {
+		cfg.MetricsPort = 9731
+	}
+	if cfg.DashboardPort <= 0 {
+		cfg.DashboardPort = 8734
+	}
+	if cfg.EventStreamPort <= 0 {
+		cfg.EventStreamPort = 8732
+	}
+	if cfg.MQTTDiscoveryPrefix == "" {
+		cfg.MQTTDiscoveryPrefix = "homeassistant"
+	}
+	if cfg.Notifier == "" {
+		cfg.Notifier = "auto"
+	}
+	if cfg.QuietHoursStart == "" {
+		cfg.QuietHoursStart = "22:00"
+	}
+	if cfg.QuietHoursEnd == "" {
+		cfg.QuietHoursEnd = "07:00"
+	}
+	if cfg.Hooks == nil {
+		cfg.Hooks = map[string]string{}
+	}
+	if cfg.HookTimeout <= 0 {
+		cfg.HookTimeout = 30
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
 
-	return &cfg, nil
+	slog.Debug("Loaded config", "path", configPath)
+
+	return cfg, nil
 }
 
 // Save writes the configuration to disk
 func (c *Config) Save() error {
-	configPath, err := getConfigPath()
+	configPath, err := Path()
 	if err != nil {
 		return err
 	}
@@ -89,5 +358,16 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	// The config holds passwords and secrets, so only the user may read it.
+	// WriteFile keeps the mode of an existing file, older versions wrote
+	// it readable by everyone.
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(configPath, 0600); err != nil {
+		return err
+	}
+
+	slog.Info("Saved config", "path", configPath)
+	return nil
 }
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Field describes a config setting for forms generated from the Config
+// struct tags
+type Field struct {
+	Key   string // the JSON name
+	Label string
+	Group string
+	Help  string
+	Unit  string
+	Kind  reflect.Kind // Bool, Int or String
+
+	// Min and Max bound Int fields, a zero Max means no upper bound
+	Min int
+	Max int
+
+	// Options lists the allowed values of a String field, if limited
+	Options []string
+
+	// Format is "password" or "clock" for String fields that need a
+	// special entry
+	Format string
+
+	index   int
+	labeled bool
+}
+
+// Fields describes the settings of Config that have a label tag, in the
+// order they are declared
+func Fields() []Field {
+	var fields []Field
+	for _, f := range taggedFields() {
+		if f.labeled {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// taggedFields describes the settings of Config that have a label, min, max
+// or options tag. Fields without a label are named after their key.
+func taggedFields() []Field {
+	var fields []Field
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		label := sf.Tag.Get("label")
+		if label == "" && sf.Tag.Get("min") == "" && sf.Tag.Get("max") == "" && sf.Tag.Get("options") == "" {
+			continue
+		}
+
+		key := strings.Split(sf.Tag.Get("json"), ",")[0]
+		f := Field{
+			Key:     key,
+			Label:   label,
+			labeled: label != "",
+			Group:   sf.Tag.Get("group"),
+			Help:    sf.Tag.Get("help"),
+			Unit:    sf.Tag.Get("unit"),
+			Kind:    sf.Type.Kind(),
+			Format:  sf.Tag.Get("format"),
+			index:   i,
+		}
+		if !f.labeled {
+			f.Label = strings.ReplaceAll(key, "_", " ")
+		}
+		f.Min, _ = strconv.Atoi(sf.Tag.Get("min"))
+		f.Max, _ = strconv.Atoi(sf.Tag.Get("max"))
+		if options := sf.Tag.Get("options"); options != "" {
+			f.Options = strings.Split(options, ",")
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Get returns the field's value in c as a bool, int or string
+func (f Field) Get(c *Config) interface{} {
+	return reflect.ValueOf(c).Elem().Field(f.index).Interface()
+}
+
+// Set sets the field in c from its text form, checking the field's bounds
+// and options
+func (f Field) Set(c *Config, text string) error {
+	v := reflect.ValueOf(c).Elem().Field(f.index)
+	switch f.Kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", f.Label)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := f.ParseInt(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.String:
+		if err := f.checkString(text); err != nil {
+			return err
+		}
+		v.SetString(text)
+	default:
+		return fmt.Errorf("%s cannot be set", f.Label)
+	}
+	return nil
+}
+
+// ParseInt parses the value of an Int field, checking its bounds
+func (f Field) ParseInt(text string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, f.boundsError()
+	}
+	return n, f.checkInt(n)
+}
+
+// check returns an error if the field's value in c is out of its bounds or
+// not one of its options
+func (f Field) check(c *Config) error {
+	switch value := f.Get(c).(type) {
+	case int:
+		return f.checkInt(value)
+	case string:
+		return f.checkString(value)
+	}
+	return nil
+}
+
+func (f Field) checkString(s string) error {
+	if len(f.Options) > 0 && !slices.Contains(f.Options, s) {
+		return fmt.Errorf("%s must be one of %s", f.Label, strings.Join(f.Options, ", "))
+	}
+	return nil
+}
+
+func (f Field) checkInt(n int) error {
+	if n < f.Min || (f.Max > 0 && n > f.Max) {
+		return f.boundsError()
+	}
+	return nil
+}
+
+func (f Field) boundsError() error {
+	if f.Max > 0 {
+		return fmt.Errorf("%s must be a whole number from %d to %d", f.Label, f.Min, f.Max)
+	}
+	return fmt.Errorf("%s must be a whole number of at least %d", f.Label, f.Min)
+}
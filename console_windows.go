@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const attachParentProcess = ^uint32(0)
+
+// attachConsole connects the GUI-subsystem executable to the console of
+// the shell that started it, so CLI output is visible
+func attachConsole() {
+	attach := windows.NewLazySystemDLL("kernel32.dll").NewProc("AttachConsole")
+	if r, _, _ := attach.Call(uintptr(attachParentProcess)); r == 0 {
+		return
+	}
+
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+	}
+}
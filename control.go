@@ -0,0 +1,103 @@
+package main
+
+import (
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/api"
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// appController exposes the tray menu operations to the control API
+type appController struct{}
+
+func (appController) Status() api.Status {
+	state, text := getState()
+	// isPaused also ends an expired timed pause
+	isPaused()
+	pausedNow, until := pauseStatus()
+
+	cfgMutex.RLock()
+	adapter := cfg.SelectedAdapter
+	target := cfg.SelectedNetwork
+	cfgMutex.RUnlock()
+
+	status := api.Status{
+		State:   state.String(),
+		Message: text,
+		Adapter: adapter,
+		Target:  target,
+		Paused:  pausedNow,
+	}
+	if pausedNow && !until.IsZero() {
+		status.PausedUntil = &until
+	}
+	if current, err := wifi.GetConnectionStatus(adapter); err == nil && current.Connected {
+		status.SSID = current.SSID
+		status.Signal = current.SignalStrength
+	}
+	return status
+}
+
+func (appController) ScanNetworks() ([]wifi.Network, error) {
+	cfgMutex.RLock()
+	adapter := cfg.SelectedAdapter
+	cfgMutex.RUnlock()
+	return wifi.ScanNetworks(adapter)
+}
+
+func (appController) SavedProfiles() ([]string, error) {
+	return wifi.GetSavedProfiles()
+}
+
+func (appController) Connect() error {
+	return attemptConnection()
+}
+
+func (appController) Disconnect() error {
+	return disconnectNow()
+}
+
+func (appController) Pause(d time.Duration) {
+	pauseAutoConnect(d)
+}
+
+func (appController) Resume() {
+	resumeAutoConnect()
+}
+
+func (appController) Config() config.Config {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
+	return *cfg.Clone()
+}
+
+func (appController) UpdateConfig(newCfg config.Config) error {
+	if err := newCfg.Validate(); err != nil {
+		return err
+	}
+	if err := newCfg.Save(); err != nil {
+		return err
+	}
+
+	applyConfig(&newCfg)
+	return nil
+}
+
+// startControlAPI serves the control API if it is enabled in the config
+func startControlAPI() (*api.Server, error) {
+	cfgMutex.RLock()
+	enabled := cfg.ControlAPIEnabled
+	port := cfg.ControlAPIPort
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		return nil, nil
+	}
+
+	token, err := api.LoadOrCreateToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.Start(port, appController{}, token)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/daemon"
+)
+
+var (
+	// daemonMode runs the connection manager without the tray or Fyne. It is
+	// set from the launch options before the config is first applied.
+	daemonMode bool
+
+	// lastPoll is when the polling loop last finished a check, in Unix
+	// nanoseconds
+	lastPoll atomic.Int64
+)
+
+// runDaemon runs the auto-connect loop until SIGTERM or SIGINT, reloading
+// the config on SIGHUP
+func runDaemon() {
+	slog.Info("Starting in daemon mode")
+
+	// The first check counts from startup for the watchdog
+	lastPoll.Store(time.Now().UnixNano())
+	stopPolling = make(chan struct{})
+	go pollWiFi()
+
+	var err error
+	apiServer, err = startControlAPI()
+	if err != nil {
+		slog.Warn("Could not start control API", "error", err)
+	}
+	metricsServer, err = startMetrics()
+	if err != nil {
+		slog.Warn("Could not start metrics endpoint", "error", err)
+	}
+	mqttClient, err = startMQTT()
+	if err != nil {
+		slog.Warn("Could not start MQTT client", "error", err)
+	}
+	eventServer, err = startEventStream()
+	if err != nil {
+		slog.Warn("Could not start event stream", "error", err)
+	}
+	dashboardServer, err = startDashboard()
+	if err != nil {
+		slog.Warn("Could not start dashboard", "error", err)
+	}
+
+	if err := daemon.Notify("READY=1"); err != nil {
+		slog.Warn("Could not notify systemd", "error", err)
+	}
+
+	stopWatchdog := make(chan struct{})
+	if interval := daemon.WatchdogInterval(); interval > 0 {
+		go pingWatchdog(interval, stopWatchdog)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			reloadConfig()
+			continue
+		}
+
+		slog.Info("Stopping", "signal", sig)
+		daemon.Notify("STOPPING=1")
+		break
+	}
+
+	close(stopWatchdog)
+	close(stopPolling)
+	if apiServer != nil {
+		apiServer.Close()
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if mqttClient != nil {
+		mqttClient.Close()
+	}
+	if eventServer != nil {
+		eventServer.Close()
+	}
+	if dashboardServer != nil {
+		dashboardServer.Close()
+	}
+}
+
+// reloadConfig re-reads the config file, keeping the current config if it
+// cannot be loaded
+func reloadConfig() {
+	daemon.Notify("RELOADING=1")
+	defer daemon.Notify("READY=1")
+
+	newCfg, err := config.Load()
+	if err != nil {
+		slog.Error("Could not reload config", "error", err)
+		return
+	}
+
+	applyConfig(newCfg)
+	slog.Info("Config reloaded")
+}
+
+// pingWatchdog keeps the systemd watchdog satisfied while the polling loop
+// is making progress, so a hung loop gets the service restarted. The loop
+// counts as alive until its next check is overdue, which allows for long
+// poll intervals and slow connection attempts.
+func pingWatchdog(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(time.Unix(0, lastPoll.Load())) < pollDeadline() {
+				daemon.Notify("WATCHDOG=1")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// pollDeadline is how long a check may take to finish after the previous
+// one before the polling loop is considered hung
+func pollDeadline() time.Duration {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
+	seconds := cfg.PollInterval + cfg.ConnectTimeout + cfg.VerifyDeadline
+	return time.Duration(seconds)*time.Second + time.Minute
+}
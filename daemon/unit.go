@@ -0,0 +1,41 @@
+package daemon
+
+import (
+	"strings"
+	"text/template"
+)
+
+// UnitOptions customizes the generated systemd unit
+type UnitOptions struct {
+	ExecPath string
+	User     string
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Quadmax WiFi Connector
+Wants=network.target
+After=network.target NetworkManager.service
+
+[Service]
+Type=notify
+ExecStart={{.ExecPath}} --daemon
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+WatchdogSec=60
+{{- if .User}}
+User={{.User}}
+{{- end}}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// UnitFile returns a systemd service unit that runs the daemon
+func UnitFile(opts UnitOptions) (string, error) {
+	var b strings.Builder
+	if err := unitTemplate.Execute(&b, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
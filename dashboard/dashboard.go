@@ -0,0 +1,278 @@
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/api"
+	"github.com/whenry/quadmax-wifi-connector/events"
+	"github.com/whenry/quadmax-wifi-connector/history"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Options configures the dashboard
+type Options struct {
+	Port int
+
+	// LAN listens on all interfaces instead of localhost only. PIN is then
+	// required to sign in.
+	LAN bool
+	PIN string
+
+	Host    string
+	Version string
+}
+
+// Server serves the dashboard
+type Server struct {
+	opts     Options
+	ctrl     api.Controller
+	history  *history.Store
+	sessions *sessions
+	signal   *signalLog
+
+	httpServer *http.Server
+	listener   net.Listener
+	done       chan struct{}
+}
+
+// Start serves the dashboard for ctrl. hist may be nil if the connection
+// history is unavailable.
+func Start(opts Options, ctrl api.Controller, hist *history.Store) (*Server, error) {
+	if opts.LAN && opts.PIN == "" {
+		return nil, errors.New("a PIN is required to serve the dashboard on the LAN")
+	}
+
+	host := "127.0.0.1"
+	if opts.LAN {
+		host = ""
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(opts.Port)))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		opts:     opts,
+		ctrl:     ctrl,
+		history:  hist,
+		sessions: newSessions(opts.PIN),
+		signal:   newSignalLog(),
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	s.signal.record(s.done)
+
+	static, _ := fs.Sub(staticFS, "static")
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("/login", s.login)
+	mux.Handle("/", s.sessions.require(http.HandlerFunc(s.index)))
+	mux.Handle("/events", s.sessions.require(events.Handler(s.done)))
+	mux.Handle("/signal", s.sessions.require(http.HandlerFunc(s.signalSamples)))
+	mux.Handle("/history", s.sessions.require(http.HandlerFunc(s.recentHistory)))
+	mux.Handle("/networks", s.sessions.require(http.HandlerFunc(s.networks)))
+	mux.Handle("/connect", s.sessions.require(s.action(s.ctrl.Connect)))
+	mux.Handle("/disconnect", s.sessions.require(s.action(s.ctrl.Disconnect)))
+	mux.Handle("/pause", s.sessions.require(s.action(func() error {
+		s.ctrl.Pause(time.Hour)
+		return nil
+	})))
+	mux.Handle("/resume", s.sessions.require(s.action(func() error {
+		s.ctrl.Resume()
+		return nil
+	})))
+
+	s.httpServer = &http.Server{Handler: s.checkHost(mux), ReadHeaderTimeout: 10 * time.Second}
+	go s.httpServer.Serve(listener)
+	slog.Info("Serving dashboard", "addr", listener.Addr().String(), "lan", opts.LAN)
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close ends open event streams and stops the server
+func (s *Server) Close() error {
+	close(s.done)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// index renders the dashboard page
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := struct {
+		Host    string
+		Version string
+		Status  api.Status
+	}{s.opts.Host, s.opts.Version, s.ctrl.Status()}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, "index.html", data); err != nil {
+		slog.Warn("Could not render dashboard", "error", err)
+	}
+}
+
+// login shows the PIN form and starts a session for the right PIN
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Host  string
+		Error string
+	}{Host: s.opts.Host}
+
+	if r.Method == http.MethodPost {
+		err := s.sessions.signIn(w, remoteIP(r), r.FormValue("pin"))
+		if err == nil {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	templates.ExecuteTemplate(w, "login.html", data)
+}
+
+func (s *Server) signalSamples(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.signal.samples())
+}
+
+// recentHistory returns the transitions of the last day, newest first
+func (s *Server) recentHistory(w http.ResponseWriter, r *http.Request) {
+	entries := []history.Entry{}
+	if s.history != nil {
+		loaded, err := s.history.Load(time.Now().Add(-24 * time.Hour))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		loaded = history.Transitions(loaded)
+		for i := len(loaded) - 1; i >= 0 && len(entries) < 50; i-- {
+			entries = append(entries, loaded[i])
+		}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) networks(w http.ResponseWriter, r *http.Request) {
+	networks, err := s.ctrl.ScanNetworks()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, networks)
+}
+
+// action runs fn for POST requests sent by the dashboard script. Requiring
+// a custom header keeps other sites from posting forms to the dashboard.
+func (s *Server) action(fn func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+			return
+		}
+		if r.Header.Get("X-Dashboard") != "1" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "missing X-Dashboard header"})
+			return
+		}
+		if err := fn(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.status())
+	})
+}
+
+// status returns the current status in the shape sent on the event stream
+func (s *Server) status() events.Status {
+	if status, ok := events.Latest(); ok {
+		return status
+	}
+	status := s.ctrl.Status()
+	return events.Status{
+		Time:    time.Now(),
+		State:   status.State,
+		Message: status.Message,
+		Target:  status.Target,
+		SSID:    status.SSID,
+		Paused:  status.Paused,
+	}
+}
+
+// checkHost rejects requests for host names other than this machine's, so
+// that a page using DNS rebinding cannot reach the dashboard through the
+// browser
+func (s *Server) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "unknown host"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the Host header names this machine: an IP
+// address, localhost or the machine's host name
+func (s *Server) allowedHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if host == "" {
+		return false
+	}
+	if net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") {
+		return true
+	}
+	return s.opts.Host != "" && (strings.EqualFold(host, s.opts.Host) || strings.EqualFold(host, s.opts.Host+".local"))
+}
+
+// remoteIP returns the address a request came from, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// URL returns the address to open the dashboard on this machine
+func URL(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/", port)
+}
@@ -0,0 +1,142 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/api"
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/events"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// fakeController reports a fixed status and counts connects
+type fakeController struct {
+	connects int
+}
+
+func (f *fakeController) Status() api.Status {
+	return api.Status{State: "connected", Message: "Connected to Quadmax", SSID: "Quadmax", Signal: "85%", Target: "Quadmax"}
+}
+func (f *fakeController) ScanNetworks() ([]wifi.Network, error) { return nil, nil }
+func (f *fakeController) SavedProfiles() ([]string, error)      { return nil, nil }
+func (f *fakeController) Connect() error                        { f.connects++; return nil }
+func (f *fakeController) Disconnect() error                     { return nil }
+func (f *fakeController) Pause(time.Duration)                   {}
+func (f *fakeController) Resume()                               {}
+func (f *fakeController) Config() config.Config                 { return *config.DefaultConfig() }
+func (f *fakeController) UpdateConfig(config.Config) error      { return nil }
+
+func startTestServer(t *testing.T, ctrl api.Controller) *Server {
+	t.Helper()
+	s, err := Start(Options{Port: 0, Host: "golf-pc"}, ctrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestActionReturnsEventStatus(t *testing.T) {
+	events.Publish(events.Status{Time: time.Now(), State: "connected", Message: "Connected to Quadmax", Target: "Quadmax", SSID: "Quadmax", Signal: 85})
+
+	ctrl := &fakeController{}
+	s := startTestServer(t, ctrl)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://"+s.Addr()+"/connect", nil)
+	req.Header.Set("X-Dashboard", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	// The page adds the percent sign itself, as for the event stream
+	var status map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status["signal"] != float64(85) || status["state"] != "connected" {
+		t.Errorf("action returned %v", status)
+	}
+	if ctrl.connects != 1 {
+		t.Errorf("Connect called %d times", ctrl.connects)
+	}
+}
+
+func TestRejectsForeignHost(t *testing.T) {
+	ctrl := &fakeController{}
+	s := startTestServer(t, ctrl)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://"+s.Addr()+"/connect", nil)
+	req.Host = "attacker.example:8734"
+	req.Header.Set("X-Dashboard", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status %d, want 403", resp.StatusCode)
+	}
+	if ctrl.connects != 0 {
+		t.Error("request for a foreign host reached the controller")
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	s := &Server{opts: Options{Host: "Golf-PC"}}
+	tests := map[string]bool{
+		"127.0.0.1:8734":         true,
+		"localhost:8734":         true,
+		"LOCALHOST":              true,
+		"[::1]:8734":             true,
+		"192.168.1.20:8734":      true,
+		"golf-pc:8734":           true,
+		"golf-pc.local:8734":     true,
+		"golf-pc.":               true,
+		"attacker.example:8734":  false,
+		"localhost.attacker.com": false,
+		"golf-pc.attacker.com":   false,
+		"":                       false,
+	}
+	for host, want := range tests {
+		if got := s.allowedHost(host); got != want {
+			t.Errorf("allowedHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestLockoutIsPerAddress(t *testing.T) {
+	s := newSessions("2468")
+
+	for i := 0; i < maxFailures; i++ {
+		if err := s.signIn(httptest.NewRecorder(), "192.168.1.50", "0000"); err != errWrongPIN {
+			t.Fatalf("attempt %d: %v, want errWrongPIN", i+1, err)
+		}
+	}
+	if err := s.signIn(httptest.NewRecorder(), "192.168.1.50", "2468"); err != errLocked {
+		t.Errorf("right PIN from a locked address: %v, want errLocked", err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := s.signIn(rec, "192.168.1.10", "2468"); err != nil {
+		t.Fatalf("other address was locked out: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie {
+		t.Fatalf("cookies %v", cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	if !s.valid(req) {
+		t.Error("session cookie not accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "quadmax_session"
+	sessionLength = 12 * time.Hour
+
+	// After maxFailures wrong PINs from an address, sign-in from it is
+	// locked for lockout
+	maxFailures = 5
+	lockout     = time.Minute
+)
+
+var (
+	errWrongPIN = errors.New("wrong PIN")
+	errLocked   = errors.New("too many attempts, try again in a minute")
+)
+
+// sessions tracks signed-in browsers. Without a PIN every request is let
+// through.
+type sessions struct {
+	pin string
+
+	mu       sync.Mutex
+	expiry   map[string]time.Time
+	attempts map[string]*attempts // by remote address
+}
+
+// attempts counts the wrong PINs sent from an address. They are forgotten
+// a lockout period after the last one.
+type attempts struct {
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+func newSessions(pin string) *sessions {
+	return &sessions{pin: pin, expiry: map[string]time.Time{}, attempts: map[string]*attempts{}}
+}
+
+// require sends requests without a valid session to the login page
+func (s *sessions) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.pin == "" || s.valid(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && r.URL.Path == "/" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "sign in first"})
+	})
+}
+
+func (s *sessions) valid(r *http.Request) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.expiry[cookie.Value]
+	if ok && time.Now().After(expiry) {
+		delete(s.expiry, cookie.Value)
+		return false
+	}
+	return ok
+}
+
+// signIn starts a session if pin is right. Wrong PINs lock out only the
+// address they came from, so other clients cannot lock out the user.
+func (s *sessions) signIn(w http.ResponseWriter, remote, pin string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for addr, a := range s.attempts {
+		if now.Sub(a.last) > lockout && now.After(a.lockedUntil) {
+			delete(s.attempts, addr)
+		}
+	}
+	a := s.attempts[remote]
+	if a != nil && now.Before(a.lockedUntil) {
+		return errLocked
+	}
+	if s.pin == "" || subtle.ConstantTimeCompare([]byte(pin), []byte(s.pin)) != 1 {
+		if a == nil {
+			a = &attempts{}
+			s.attempts[remote] = a
+		}
+		a.failures++
+		a.last = now
+		if a.failures >= maxFailures {
+			a.failures = 0
+			a.lockedUntil = now.Add(lockout)
+		}
+		return errWrongPIN
+	}
+	delete(s.attempts, remote)
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(b)
+	s.expiry[id] = now.Add(sessionLength)
+	for id, expiry := range s.expiry {
+		if now.After(expiry) {
+			delete(s.expiry, id)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionLength.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
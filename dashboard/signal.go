@@ -0,0 +1,65 @@
+package dashboard
+
+import (
+	"sync"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/events"
+)
+
+// maxSamples keeps about an hour of signal samples at the default poll
+// interval
+const maxSamples = 720
+
+// sample is a signal reading for the graph
+type sample struct {
+	Time   time.Time `json:"time"`
+	Signal int       `json:"signal"`
+}
+
+// signalLog keeps recent signal readings so the graph is not empty when
+// the page opens
+type signalLog struct {
+	mu   sync.Mutex
+	list []sample
+}
+
+func newSignalLog() *signalLog {
+	return &signalLog{}
+}
+
+// record adds the current status and every one published after it until
+// done is closed
+func (l *signalLog) record(done <-chan struct{}) {
+	updates, cancel := events.Subscribe()
+	if s, ok := events.Latest(); ok {
+		l.add(s)
+	}
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case s := <-updates:
+				l.add(s)
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (l *signalLog) add(s events.Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = append(l.list, sample{Time: s.Time, Signal: s.Signal})
+	if len(l.list) > maxSamples {
+		l.list = l.list[len(l.list)-maxSamples:]
+	}
+}
+
+func (l *signalLog) samples() []sample {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]sample{}, l.list...)
+}
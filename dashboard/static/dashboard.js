@@ -0,0 +1,137 @@
+"use strict";
+
+const samples = [];
+const graphWindow = 60 * 60 * 1000;
+
+function $(id) {
+  return document.getElementById(id);
+}
+
+async function request(path, options) {
+  const response = await fetch(path, options);
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+function showStatus(status) {
+  $("state-dot").className = "dot " + status.state;
+  $("message").textContent = status.message;
+  $("target").textContent = status.target;
+  $("signal").textContent = status.state === "connected" ? status.signal + "%" : "";
+  $("paused").textContent = status.paused ? "Paused" : "On";
+}
+
+function drawGraph() {
+  const canvas = $("signal-graph");
+  const ctx = canvas.getContext("2d");
+  const now = Date.now();
+  const x = (t) => ((t - (now - graphWindow)) / graphWindow) * canvas.width;
+  const y = (signal) => canvas.height - (signal / 100) * canvas.height;
+
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  ctx.strokeStyle = "#eee";
+  for (const level of [25, 50, 75]) {
+    ctx.beginPath();
+    ctx.moveTo(0, y(level));
+    ctx.lineTo(canvas.width, y(level));
+    ctx.stroke();
+  }
+
+  // Readings only arrive on change, so each one holds until the next
+  ctx.strokeStyle = "#007acc";
+  ctx.lineWidth = 2;
+  ctx.beginPath();
+  samples.forEach((sample, i) => {
+    const t = Math.max(sample.time, now - graphWindow);
+    if (i === 0) {
+      ctx.moveTo(x(t), y(sample.signal));
+    } else {
+      ctx.lineTo(x(t), y(samples[i - 1].signal));
+      ctx.lineTo(x(t), y(sample.signal));
+    }
+  });
+  if (samples.length > 0) {
+    ctx.lineTo(canvas.width, y(samples[samples.length - 1].signal));
+  }
+  ctx.stroke();
+}
+
+function addSample(time, signal) {
+  samples.push({ time: new Date(time).getTime(), signal: signal });
+  while (samples.length > 1 && samples[1].time < Date.now() - graphWindow) {
+    samples.shift();
+  }
+  drawGraph();
+}
+
+async function loadHistory() {
+  const entries = await request("/history");
+  const rows = entries.map((entry) => {
+    const row = document.createElement("tr");
+    const details = [entry.reason, entry.ssid, entry.signal ? entry.signal + "%" : ""];
+    for (const text of [new Date(entry.time).toLocaleString(), entry.state, details.filter(Boolean).join(", ")]) {
+      const cell = document.createElement("td");
+      cell.textContent = text;
+      row.appendChild(cell);
+    }
+    return row;
+  });
+  $("history").replaceChildren(...rows);
+}
+
+async function scan() {
+  $("scan").disabled = true;
+  try {
+    const networks = await request("/networks");
+    const items = networks.map((network) => {
+      const item = document.createElement("li");
+      item.textContent = network.ssid;
+      return item;
+    });
+    $("networks").replaceChildren(...items);
+  } catch (err) {
+    $("networks").textContent = err.message;
+  } finally {
+    $("scan").disabled = false;
+  }
+}
+
+async function runAction(button) {
+  const action = button.dataset.action;
+  button.disabled = true;
+  $("action-message").textContent = "";
+  try {
+    showStatus(await request("/" + action, { method: "POST", headers: { "X-Dashboard": "1" } }));
+  } catch (err) {
+    $("action-message").textContent = err.message;
+  } finally {
+    button.disabled = false;
+  }
+}
+
+function listen() {
+  const stream = new EventSource("/events");
+  stream.addEventListener("status", (event) => {
+    const status = JSON.parse(event.data);
+    showStatus(status);
+    addSample(status.time, status.signal);
+    if (status.message !== listen.lastMessage) {
+      listen.lastMessage = status.message;
+      loadHistory().catch(() => {});
+    }
+  });
+}
+
+document.querySelectorAll("button[data-action]").forEach((button) => {
+  button.addEventListener("click", () => runAction(button));
+});
+$("scan").addEventListener("click", scan);
+
+request("/signal")
+  .then((list) => list.forEach((sample) => addSample(sample.time, sample.signal)))
+  .catch(() => {})
+  .finally(listen);
+setInterval(drawGraph, 10000);
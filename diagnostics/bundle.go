@@ -0,0 +1,152 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/history"
+	"github.com/whenry/quadmax-wifi-connector/logging"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+const bundleDir = "diagnostics"
+
+// Options describes what goes into a bundle
+type Options struct {
+	Version string
+	Adapter string
+	Network string
+}
+
+// DefaultPath returns a new timestamped bundle path in the config directory
+func DefaultPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("quadmax-diagnostics-%s.zip", time.Now().Format("20060102-150405"))
+	return filepath.Join(configDir, bundleDir, name), nil
+}
+
+// CreateBundle writes a zip with the redacted config, logs, connection
+// history and netsh output to path. Missing pieces are noted in the bundle
+// rather than failing it.
+func CreateBundle(path string, opts Options) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(file)
+	var problems []string
+	note := func(what string, err error) {
+		problems = append(problems, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	addConfig(zw, note)
+	addLogs(zw, note)
+	addHistory(zw, note)
+	for _, q := range wifi.DiagnosticQueries(opts.Adapter, opts.Network) {
+		text := fmt.Sprintf("> netsh %s\n\n%s", strings.Join(q.Args, " "), q.Output)
+		if q.Err != nil {
+			text += fmt.Sprintf("\nerror: %v\n", q.Err)
+		}
+		addFile(zw, "netsh/"+q.Name+".txt", []byte(Redact(text)), note)
+	}
+	addFile(zw, "info.txt", []byte(describeApp(opts, problems)), note)
+
+	if err := zw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func addFile(zw *zip.Writer, name string, data []byte, note func(string, error)) {
+	w, err := zw.Create(name)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err != nil {
+		note(name, err)
+	}
+}
+
+func addConfig(zw *zip.Writer, note func(string, error)) {
+	path, err := config.Path()
+	if err != nil {
+		note("config", err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		note("config", err)
+		return
+	}
+	data, err = RedactJSON(data)
+	if err != nil {
+		note("config", err)
+		return
+	}
+	addFile(zw, "config.json", data, note)
+}
+
+func addLogs(zw *zip.Writer, note func(string, error)) {
+	dir, err := logging.Dir()
+	if err != nil {
+		note("logs", err)
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.log*"))
+	if err != nil || len(paths) == 0 {
+		note("logs", fmt.Errorf("no log files in %s", dir))
+		return
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			note(path, err)
+			continue
+		}
+		addFile(zw, "logs/"+filepath.Base(path), []byte(Redact(string(data))), note)
+	}
+}
+
+func addHistory(zw *zip.Writer, note func(string, error)) {
+	path, err := history.DefaultPath()
+	if err != nil {
+		note("history", err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		note("history", err)
+		return
+	}
+	addFile(zw, "history.jsonl", data, note)
+}
+
+func describeApp(opts Options, problems []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Quadmax WiFi Connector %s\n", opts.Version)
+	fmt.Fprintf(&b, "Created:  %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Platform: %s/%s (%s)\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(&b, "Adapter:  %s\n", opts.Adapter)
+	fmt.Fprintf(&b, "Network:  %s\n", opts.Network)
+	if len(problems) > 0 {
+		b.WriteString("\nNot included:\n")
+		for _, p := range problems {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// Sensitive "Key : Value" lines as printed by netsh
+	sensitiveLine = regexp.MustCompile(`(?im)^(\s*(?:key content|key material|password|passphrase)\s*:\s*).*$`)
+
+	// Sensitive key=value pairs, as written by the logger
+	sensitivePair = regexp.MustCompile(`(?i)\b(\w*(?:password|passphrase|secret|token|psk)\w*\s*[=:]\s*)("(?:[^"\\]|\\.)*"|\S+)`)
+)
+
+// Redact masks passwords, keys and tokens in netsh output and log text
+func Redact(text string) string {
+	text = sensitiveLine.ReplaceAllString(text, "${1}"+redacted)
+	return sensitivePair.ReplaceAllString(text, "${1}"+redacted)
+}
+
+// isSensitiveKey reports whether a config field may hold a secret
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "passphrase", "secret", "token", "psk"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return strings.HasSuffix(key, "pin") || strings.HasSuffix(key, "key")
+}
+
+// RedactJSON masks the values of sensitive fields anywhere in a JSON
+// document, such as the config file. Hook commands are masked as they
+// often carry tokens, and URLs lose their credentials and query.
+func RedactJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(doc), "", "  ")
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch {
+			case isSensitiveKey(key):
+				v[key] = redactAll(value)
+			case strings.EqualFold(key, "hooks"):
+				if hooks, ok := value.(map[string]interface{}); ok {
+					for event, command := range hooks {
+						hooks[event] = redactAll(command)
+					}
+					continue
+				}
+				v[key] = redactAll(value)
+			default:
+				v[key] = redactValue(value)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	case string:
+		return redactURLString(v)
+	default:
+		return v
+	}
+}
+
+// redactAll masks a secret value, leaving empty strings so it is clear the
+// secret was never set
+func redactAll(v interface{}) interface{} {
+	if s, ok := v.(string); ok && s == "" {
+		return s
+	}
+	return redacted
+}
+
+// redactURLString masks the user info and query of s if it is a URL
+func redactURLString(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.User == nil && u.RawQuery == "") {
+		return s
+	}
+
+	hasUser, hasQuery := u.User != nil, u.RawQuery != ""
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	text := u.String()
+	if hasUser {
+		text = strings.Replace(text, "://", "://"+redacted+"@", 1)
+	}
+	if hasQuery {
+		text += "?" + redacted
+	}
+	return text
+}
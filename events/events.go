@@ -0,0 +1,135 @@
+package events
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Status is the connection state as seen by other apps
+type Status struct {
+	Time    time.Time `json:"time"`
+	State   string    `json:"state"`
+	Message string    `json:"message"`
+	Target  string    `json:"target"`
+	SSID    string    `json:"ssid,omitempty"`
+	Signal  int       `json:"signal"` // percent
+	Paused  bool      `json:"paused"`
+}
+
+// same reports whether two statuses differ only in their time
+func (s Status) same(other Status) bool {
+	s.Time = other.Time
+	return s == other
+}
+
+var (
+	mu          sync.Mutex
+	latest      Status
+	published   bool
+	subscribers = map[chan Status]bool{}
+	filePath    string
+
+	// fileMu orders the writes of the status file, so that an older status
+	// never replaces a newer one. It is taken before mu.
+	fileMu sync.Mutex
+)
+
+// SetFile sets the file the status is written to on every change, or
+// stops writing it if path is empty
+func SetFile(path string) {
+	mu.Lock()
+	changed := path != filePath
+	filePath = path
+	mu.Unlock()
+
+	if changed {
+		syncFile()
+	}
+}
+
+// Publish passes s on to the status file and subscribers if it changed
+func Publish(s Status) {
+	mu.Lock()
+	if published && s.same(latest) {
+		mu.Unlock()
+		return
+	}
+	latest = s
+	published = true
+	for ch := range subscribers {
+		select {
+		case ch <- s:
+		default:
+			// A slow subscriber misses this update but gets the next
+		}
+	}
+	mu.Unlock()
+
+	syncFile()
+}
+
+// syncFile writes the latest status to the status file, if one is set
+func syncFile() {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	mu.Lock()
+	path, current, ok := filePath, latest, published
+	mu.Unlock()
+
+	if path != "" && ok {
+		writeFile(path, current)
+	}
+}
+
+// Latest returns the last published status
+func Latest() (Status, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	return latest, published
+}
+
+// Subscribe returns a channel receiving every published status. cancel
+// must be called when done.
+func Subscribe() (updates <-chan Status, cancel func()) {
+	ch := make(chan Status, 8)
+	mu.Lock()
+	subscribers[ch] = true
+	mu.Unlock()
+
+	return ch, func() {
+		mu.Lock()
+		delete(subscribers, ch)
+		mu.Unlock()
+	}
+}
+
+// writeFile replaces the status file atomically so readers never see a
+// partly written file
+func writeFile(path string, s Status) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*.tmp")
+	if err == nil {
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		slog.Warn("Could not write status file", "path", path, "error", err)
+	}
+}
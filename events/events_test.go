@@ -0,0 +1,178 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reset clears the published status and the status file between tests
+func reset(t *testing.T) {
+	t.Helper()
+	SetFile("")
+	mu.Lock()
+	latest, published = Status{}, false
+	mu.Unlock()
+	t.Cleanup(func() { SetFile("") })
+}
+
+func TestPublishDedup(t *testing.T) {
+	reset(t)
+	updates, cancel := Subscribe()
+	defer cancel()
+
+	connected := Status{Time: time.Now(), State: "connected", Message: "Connected to Quadmax", Target: "Quadmax", SSID: "Quadmax", Signal: 85}
+	Publish(connected)
+
+	// Only the time differs, so nothing is sent
+	again := connected
+	again.Time = connected.Time.Add(time.Minute)
+	Publish(again)
+
+	weaker := connected
+	weaker.Signal = 60
+	Publish(weaker)
+
+	for _, want := range []int{85, 60} {
+		select {
+		case s := <-updates:
+			if s.Signal != want {
+				t.Errorf("received signal %d, want %d", s.Signal, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no update with signal %d", want)
+		}
+	}
+	select {
+	case s := <-updates:
+		t.Errorf("received unexpected update %+v", s)
+	default:
+	}
+
+	if s, ok := Latest(); !ok || s.Signal != 60 {
+		t.Errorf("Latest = %+v, %v", s, ok)
+	}
+}
+
+func TestStatusFileConcurrent(t *testing.T) {
+	reset(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.json")
+	SetFile(path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Publish(Status{Time: time.Now(), State: "connected", Message: fmt.Sprintf("update %d", i), Signal: i})
+		}(i)
+	}
+	wg.Wait()
+
+	// The file holds the latest status, whole
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Status
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("status file is not valid JSON: %v\n%s", err, data)
+	}
+	latest, _ := Latest()
+	if !written.same(latest) || !written.Time.Equal(latest.Time) {
+		t.Errorf("status file has %+v, latest is %+v", written, latest)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the status file", len(entries))
+	}
+}
+
+func TestSetFileWritesLatest(t *testing.T) {
+	reset(t)
+	Publish(Status{Time: time.Now(), State: "searching", Message: "Connecting to Quadmax..."})
+
+	path := filepath.Join(t.TempDir(), "status.json")
+	SetFile(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"state": "searching"`) {
+		t.Errorf("status file = %s", data)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reset(t)
+	Publish(Status{Time: time.Now(), State: "searching", Message: "Connecting to Quadmax..."})
+
+	done := make(chan struct{})
+	server := httptest.NewServer(Handler(done))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+
+	// readEvent returns the status of the next event on the stream
+	readEvent := func() Status {
+		t.Helper()
+		var event, data string
+		for lines.Scan() {
+			line := lines.Text()
+			if line == "" && event != "" {
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				event = value
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data = value
+			}
+		}
+		if event != "status" {
+			t.Fatalf("event = %q, want status", event)
+		}
+		var s Status
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			t.Fatalf("data %q: %v", data, err)
+		}
+		return s
+	}
+
+	// The stream starts with the current status
+	if s := readEvent(); s.State != "searching" {
+		t.Errorf("first event state = %q, want searching", s.State)
+	}
+
+	Publish(Status{Time: time.Now(), State: "connected", Message: "Connected to Quadmax", SSID: "Quadmax", Signal: 85})
+	if s := readEvent(); s.State != "connected" || s.Signal != 85 {
+		t.Errorf("second event = %+v", s)
+	}
+
+	// Closing done ends the stream
+	close(done)
+	for lines.Scan() {
+	}
+}
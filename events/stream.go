@@ -0,0 +1,100 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// keepAlive is how often an idle stream gets a comment so that proxies
+// and clients don't time out
+const keepAlive = 15 * time.Second
+
+// Handler serves the status as a Server-Sent Events stream. Each status
+// is a "status" event with the JSON status as data, starting with the
+// current one. done ends all streams when closed.
+func Handler(done <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Overlays are often local files, which have no origin to allow
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		updates, cancel := Subscribe()
+		defer cancel()
+
+		if s, ok := Latest(); ok {
+			writeEvent(w, s)
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case s := <-updates:
+				writeEvent(w, s)
+			case <-ticker.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-r.Context().Done():
+				return
+			case <-done:
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, s Status) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+}
+
+// Server serves the event stream
+type Server struct {
+	httpServer *http.Server
+	done       chan struct{}
+}
+
+// Start serves the event stream at /events and the current status at
+// /status on 127.0.0.1 at port
+func Start(port int) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{done: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.Handle("/events", Handler(s.done))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status, _ := Latest()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(status)
+	})
+	s.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.httpServer.Serve(listener)
+	return s, nil
+}
+
+// Close ends the open streams and stops the server
+func (s *Server) Close() error {
+	close(s.done)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,206 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+)
+
+const (
+	historyFile = "history.jsonl"
+
+	// Once the file passes maxSize it is pruned to the newest keepSize bytes
+	maxSize  = 2 * 1024 * 1024
+	keepSize = maxSize / 2
+)
+
+// States recorded in the history. The connection states match the names
+// used by the app; stopped marks the app exiting.
+const (
+	StateConnected    = "connected"
+	StateSearching    = "searching"
+	StateDisconnected = "disconnected"
+	StateStopped      = "stopped"
+)
+
+// Entry is a connection state transition
+type Entry struct {
+	Time   time.Time `json:"time"`
+	State  string    `json:"state"`
+	SSID   string    `json:"ssid,omitempty"`
+	BSSID  string    `json:"bssid,omitempty"`
+	Signal int       `json:"signal,omitempty"` // percent
+	Reason string    `json:"reason,omitempty"`
+
+	// Sample marks a periodic signal reading taken while connected rather
+	// than a state change
+	Sample bool `json:"sample,omitempty"`
+
+	// UserInitiated marks a transition caused by the user, such as Connect
+	// Now or a new target network, which is not a drop
+	UserInitiated bool `json:"user_initiated,omitempty"`
+}
+
+// Transitions returns entries without the signal samples
+func Transitions(entries []Entry) []Entry {
+	var transitions []Entry
+	for _, e := range entries {
+		if !e.Sample {
+			transitions = append(transitions, e)
+		}
+	}
+	return transitions
+}
+
+// Store is an append-only JSON Lines file of entries
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultPath returns the path of the history file in the config directory
+func DefaultPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, historyFile), nil
+}
+
+// Open returns a store for the history file at path
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// OpenDefault returns a store for the default history file
+func OpenDefault() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Open(path), nil
+}
+
+// Path returns the path of the history file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append adds an entry, pruning the oldest entries if the file is too big
+func (s *Store) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	info, statErr := file.Stat()
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	if statErr == nil && info.Size() > maxSize {
+		return s.prune()
+	}
+	return nil
+}
+
+// prune rewrites the file keeping only the newest entries. The oldest
+// signal samples go first, so samples taken every few minutes do not push
+// out the transitions.
+func (s *Store) prune() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	if len(data) <= keepSize {
+		return nil
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	keep := make([]bool, len(lines))
+	size := len(data)
+	for i, line := range lines {
+		keep[i] = true
+		if size > keepSize && !isTransition(line) {
+			keep[i] = false
+			size -= len(line)
+		}
+	}
+	for i, line := range lines {
+		if size <= keepSize {
+			break
+		}
+		if keep[i] {
+			keep[i] = false
+			size -= len(line)
+		}
+	}
+
+	kept := make([]byte, 0, size)
+	for i, line := range lines {
+		if keep[i] {
+			kept = append(kept, line...)
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// isTransition reports whether line holds an entry that is not a sample
+func isTransition(line []byte) bool {
+	var e Entry
+	return json.Unmarshal(line, &e) == nil && !e.Sample
+}
+
+// Load returns the entries recorded at or after since, oldest first.
+// Unreadable lines are skipped.
+func (s *Store) Load(since time.Time) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,131 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreAppendLoad(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	if entries, err := store.Load(at(0)); err != nil || entries != nil {
+		t.Fatalf("Load of a missing file = %v, %v", entries, err)
+	}
+
+	for i, state := range []string{StateConnected, StateSearching, StateConnected} {
+		if err := store.Append(Entry{Time: at(i * 10), State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unreadable lines are skipped
+	file, err := os.OpenFile(store.Path(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("not json\n")
+	file.Close()
+
+	entries, err := store.Load(at(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].State != StateSearching || entries[1].State != StateConnected {
+		t.Errorf("Load since the second entry = %+v", entries)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	// Append entries until the file passes maxSize and gets pruned
+	var appended int
+	var size int64
+	for {
+		if err := store.Append(Entry{Time: at(appended), State: StateConnected, Reason: "Connected to Quadmax"}); err != nil {
+			t.Fatal(err)
+		}
+		appended++
+		info, err := os.Stat(store.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() < size {
+			if info.Size() > keepSize {
+				t.Fatalf("pruned to %d bytes, want at most %d", info.Size(), keepSize)
+			}
+			break
+		}
+		size = info.Size()
+		if size > 2*maxSize {
+			t.Fatal("history was never pruned")
+		}
+	}
+
+	entries, err := store.Load(at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) >= appended {
+		t.Fatalf("kept %d of %d entries", len(entries), appended)
+	}
+
+	// The newest entries are kept in order, starting at a whole line
+	for i, e := range entries {
+		if want := at(appended - len(entries) + i); !e.Time.Equal(want) {
+			t.Fatalf("entry %d at %v, want %v", i, e.Time, want)
+		}
+	}
+}
+
+func TestStorePruneSamplesFirst(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	transitions := []string{StateSearching, StateConnected, StateDisconnected}
+	for i, state := range transitions {
+		if err := store.Append(Entry{Time: at(i), State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Signal samples fill the file until it gets pruned
+	var appended int
+	var size int64
+	for {
+		if err := store.Append(Entry{Time: at(len(transitions) + appended), State: StateConnected, Signal: 80, Sample: true}); err != nil {
+			t.Fatal(err)
+		}
+		appended++
+		info, err := os.Stat(store.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() < size {
+			break
+		}
+		size = info.Size()
+		if size > 2*maxSize {
+			t.Fatal("history was never pruned")
+		}
+	}
+
+	entries, err := store.Load(at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := Transitions(entries)
+	if len(kept) != len(transitions) {
+		t.Fatalf("kept transitions %+v, want all %d", kept, len(transitions))
+	}
+	for i, e := range kept {
+		if e.State != transitions[i] {
+			t.Errorf("transition %d = %s, want %s", i, e.State, transitions[i])
+		}
+	}
+
+	// The newest sample is kept
+	if last := entries[len(entries)-1]; !last.Sample || !last.Time.Equal(at(len(transitions)+appended-1)) {
+		t.Errorf("last entry = %+v", last)
+	}
+}
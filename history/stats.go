@@ -0,0 +1,154 @@
+package history
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DayStats is the connection uptime for one calendar day
+type DayStats struct {
+	Date      time.Time     `json:"date"`
+	Connected time.Duration `json:"connected"`
+	Observed  time.Duration `json:"observed"`
+}
+
+// Uptime returns the connected share of the observed time in percent
+func (d DayStats) Uptime() float64 {
+	if d.Observed <= 0 {
+		return 0
+	}
+	return 100 * float64(d.Connected) / float64(d.Observed)
+}
+
+// Stats summarizes the history over a period
+type Stats struct {
+	Days      []DayStats    `json:"days"`
+	Connected time.Duration `json:"connected"`
+	Observed  time.Duration `json:"observed"`
+	Drops     int           `json:"drops"`
+
+	// MeanTimeBetweenDrops is the connected time divided by the drops
+	MeanTimeBetweenDrops time.Duration `json:"mean_time_between_drops"`
+
+	// Reconnects are the times from each drop to the next connection
+	Reconnects    []time.Duration `json:"reconnects"`
+	MeanReconnect time.Duration   `json:"mean_reconnect"`
+	MaxReconnect  time.Duration   `json:"max_reconnect"`
+}
+
+// Uptime returns the connected share of the observed time in percent
+func (s Stats) Uptime() float64 {
+	if s.Observed <= 0 {
+		return 0
+	}
+	return 100 * float64(s.Connected) / float64(s.Observed)
+}
+
+// Compute summarizes entries between from and to. Each entry's state lasts
+// until the next entry; time after a "stopped" entry is not observed. Signal
+// samples only repeat the connected state and do not affect the result.
+func Compute(entries []Entry, from, to time.Time) Stats {
+	var stats Stats
+	days := map[time.Time]*DayStats{}
+	var order []time.Time
+
+	addSpan := func(start, end time.Time, connected bool) {
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		// Split the span at midnight so each day gets its share
+		for start.Before(end) {
+			day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+			next := day.AddDate(0, 0, 1)
+			spanEnd := end
+			if next.Before(spanEnd) {
+				spanEnd = next
+			}
+
+			d, ok := days[day]
+			if !ok {
+				d = &DayStats{Date: day}
+				days[day] = d
+				order = append(order, day)
+			}
+			d.Observed += spanEnd.Sub(start)
+			stats.Observed += spanEnd.Sub(start)
+			if connected {
+				d.Connected += spanEnd.Sub(start)
+				stats.Connected += spanEnd.Sub(start)
+			}
+			start = spanEnd
+		}
+	}
+
+	var dropTime time.Time
+	for i, e := range entries {
+		end := to
+		if i+1 < len(entries) {
+			end = entries[i+1].Time
+		}
+		if e.State != StateStopped {
+			addSpan(e.Time, end, e.State == StateConnected)
+		}
+
+		if i == 0 || e.Time.Before(from) || e.Time.After(to) {
+			continue
+		}
+		// Leaving the connected state for anything but an exit or a user
+		// request is a drop
+		prev := entries[i-1]
+		if prev.State == StateConnected && e.State != StateConnected && e.State != StateStopped && !e.UserInitiated {
+			stats.Drops++
+			dropTime = e.Time
+		}
+		if e.State == StateConnected && !dropTime.IsZero() {
+			reconnect := e.Time.Sub(dropTime)
+			stats.Reconnects = append(stats.Reconnects, reconnect)
+			if reconnect > stats.MaxReconnect {
+				stats.MaxReconnect = reconnect
+			}
+			dropTime = time.Time{}
+		}
+		if e.State == StateStopped || e.UserInitiated {
+			dropTime = time.Time{}
+		}
+	}
+
+	for _, day := range order {
+		stats.Days = append(stats.Days, *days[day])
+	}
+	if stats.Drops > 0 {
+		stats.MeanTimeBetweenDrops = stats.Connected / time.Duration(stats.Drops)
+	}
+	if len(stats.Reconnects) > 0 {
+		var total time.Duration
+		for _, r := range stats.Reconnects {
+			total += r
+		}
+		stats.MeanReconnect = total / time.Duration(len(stats.Reconnects))
+	}
+	return stats
+}
+
+// WriteCSV writes entries as CSV with a header row
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "state", "ssid", "bssid", "signal", "reason"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.State,
+			e.SSID,
+			e.BSSID,
+			strconv.Itoa(e.Signal),
+			e.Reason,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
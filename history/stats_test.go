@@ -0,0 +1,99 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC)
+
+// at returns the time minutes after base
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestCompute(t *testing.T) {
+	entries := []Entry{
+		{Time: at(0), State: StateConnected},
+		{Time: at(30), State: StateConnected, Signal: 70, Sample: true},
+		{Time: at(60), State: StateSearching},                          // drop
+		{Time: at(62), State: StateConnected},                          // reconnect after 2m
+		{Time: at(90), State: StateSearching, UserInitiated: true},     // Connect Now
+		{Time: at(91), State: StateConnected},                          // not a reconnect
+		{Time: at(120), State: StateDisconnected},                      // drop, crosses midnight
+		{Time: at(130), State: StateStopped},                           // app exit ends the outage
+		{Time: at(150), State: StateConnected},                         // not a reconnect
+		{Time: at(170), State: StateDisconnected, UserInitiated: true}, // Disconnect
+	}
+	stats := Compute(entries, at(0), at(180))
+
+	if stats.Drops != 2 {
+		t.Errorf("Drops = %d, want 2", stats.Drops)
+	}
+	if len(stats.Reconnects) != 1 || stats.Reconnects[0] != 2*time.Minute {
+		t.Errorf("Reconnects = %v, want [2m]", stats.Reconnects)
+	}
+	if stats.MaxReconnect != 2*time.Minute || stats.MeanReconnect != 2*time.Minute {
+		t.Errorf("reconnect max %v, mean %v, want 2m", stats.MaxReconnect, stats.MeanReconnect)
+	}
+
+	// Connected 60+28+29+20 minutes out of 180 less the 20 stopped
+	if stats.Connected != 137*time.Minute || stats.Observed != 160*time.Minute {
+		t.Errorf("connected %v of %v, want 2h17m of 2h40m", stats.Connected, stats.Observed)
+	}
+	if stats.MeanTimeBetweenDrops != stats.Connected/2 {
+		t.Errorf("MeanTimeBetweenDrops = %v, want %v", stats.MeanTimeBetweenDrops, stats.Connected/2)
+	}
+
+	if len(stats.Days) != 2 {
+		t.Fatalf("got %d days, want 2", len(stats.Days))
+	}
+	if d := stats.Days[0]; d.Observed != 2*time.Hour || d.Connected != 117*time.Minute {
+		t.Errorf("first day connected %v of %v, want 1h57m of 2h", d.Connected, d.Observed)
+	}
+	if d := stats.Days[1]; d.Observed != 40*time.Minute || d.Connected != 20*time.Minute {
+		t.Errorf("second day connected %v of %v, want 20m of 40m", d.Connected, d.Observed)
+	}
+}
+
+func TestComputeClipsToPeriod(t *testing.T) {
+	entries := []Entry{
+		{Time: at(-60), State: StateConnected},
+		{Time: at(-30), State: StateSearching},
+		{Time: at(-29), State: StateConnected},
+		{Time: at(30), State: StateDisconnected},
+	}
+	stats := Compute(entries, at(0), at(60))
+
+	if stats.Drops != 1 {
+		t.Errorf("Drops = %d, want only the drop inside the period", stats.Drops)
+	}
+	if stats.Connected != 30*time.Minute || stats.Observed != time.Hour {
+		t.Errorf("connected %v of %v, want 30m of 1h", stats.Connected, stats.Observed)
+	}
+	if got := stats.Uptime(); got != 50 {
+		t.Errorf("Uptime = %v, want 50", got)
+	}
+	if got := Compute(nil, at(0), at(60)); got.Uptime() != 0 || got.Drops != 0 {
+		t.Errorf("empty history gave %+v", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	entries := []Entry{
+		{Time: at(0), State: StateConnected, SSID: "Quadmax", BSSID: "aa:bb:cc:dd:ee:ff", Signal: 85, Reason: "Connected to Quadmax"},
+		{Time: at(5), State: StateDisconnected, Reason: `Lost "Quadmax", retrying`},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "time,state,ssid,bssid,signal,reason\n" +
+		"2026-03-10T22:00:00Z,connected,Quadmax,aa:bb:cc:dd:ee:ff,85,Connected to Quadmax\n" +
+		"2026-03-10T22:05:00Z,disconnected,,,0,\"Lost \"\"Quadmax\"\", retrying\"\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
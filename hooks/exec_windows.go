@@ -0,0 +1,42 @@
+//go:build windows
+
+package hooks
+
+import (
+	"log/slog"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// startCommand starts cmd without a console window, in a job object so
+// that a timeout kills everything the shell started. It returns a function
+// to call once the shell has exited, which closes the job and leaves
+// programs started in the background running.
+func startCommand(cmd *exec.Cmd) (func(), error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Cancel = func() error {
+		windows.TerminateJobObject(job, 1)
+		return cmd.Process.Kill()
+	}
+	if err := cmd.Start(); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err == nil {
+		err = windows.AssignProcessToJobObject(job, process)
+		windows.CloseHandle(process)
+	}
+	if err != nil {
+		slog.Warn("Could not add hook to a job object, a timeout only stops the shell", "error", err)
+	}
+	return func() { windows.CloseHandle(job) }, nil
+}
@@ -0,0 +1,147 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Events that can have a hook command
+const (
+	EventConnected      = "connected"
+	EventDisconnected   = "disconnected"
+	EventConnectFailed  = "connect_failed"
+	EventNotInRange     = "not_in_range"
+	EventAdapterMissing = "adapter_missing"
+)
+
+// Events lists every event that can have a hook command
+var Events = []string{
+	EventConnected,
+	EventDisconnected,
+	EventConnectFailed,
+	EventNotInRange,
+	EventAdapterMissing,
+}
+
+// Info describes the event a hook runs for. It is passed to the hook
+// command as QUADMAX_* environment variables.
+type Info struct {
+	Event         string
+	SSID          string
+	Adapter       string
+	Signal        int // in percent, or -1 if unknown
+	PreviousState string
+}
+
+// Env returns the environment variables describing the event
+func (i Info) Env() []string {
+	signal := ""
+	if i.Signal >= 0 {
+		signal = strconv.Itoa(i.Signal)
+	}
+	return []string{
+		"QUADMAX_EVENT=" + i.Event,
+		"QUADMAX_SSID=" + i.SSID,
+		"QUADMAX_ADAPTER=" + i.Adapter,
+		"QUADMAX_SIGNAL=" + signal,
+		"QUADMAX_PREVIOUS_STATE=" + i.PreviousState,
+	}
+}
+
+// Run runs command through the shell with the event in its environment.
+// Hooks must be short-lived: the shell and everything it started are
+// killed after timeout. Programs the hook starts in the background, with
+// "&" or "start", keep running once the shell has exited. The output of
+// the shell is written to the log.
+func Run(command string, info Info, timeout time.Duration) error {
+	// Output goes to a file rather than a pipe, so programs left running
+	// in the background do not hold Run up
+	output, err := os.CreateTemp("", "quadmax-hook-*.log")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), info.Env()...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	release, err := startCommand(cmd)
+	if err != nil {
+		slog.Warn("Hook failed", "event", info.Event, "error", err)
+		return err
+	}
+	err = cmd.Wait()
+	release()
+	duration := time.Since(start)
+
+	if _, seekErr := output.Seek(0, io.SeekStart); seekErr == nil {
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			slog.Info("Hook output", "event", info.Event, "line", scanner.Text())
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Warn("Hook timed out", "event", info.Event, "timeout", timeout)
+		return ctx.Err()
+	}
+	if err != nil {
+		slog.Warn("Hook failed", "event", info.Event, "duration", duration, "error", err)
+		return err
+	}
+	slog.Info("Hook finished", "event", info.Event, "duration", duration)
+	return nil
+}
+
+// Runner runs hooks one at a time in the order their events happened, so
+// that a disconnect hook never overtakes the connect hook before it
+type Runner struct {
+	queue chan job
+}
+
+type job struct {
+	command string
+	info    Info
+	timeout time.Duration
+}
+
+// NewRunner starts a runner
+func NewRunner() *Runner {
+	r := &Runner{queue: make(chan job, 32)}
+	go func() {
+		for j := range r.queue {
+			Run(j.command, j.info, j.timeout)
+		}
+	}()
+	return r
+}
+
+// Fire queues command to run for the event. Events are dropped if too
+// many hooks are waiting.
+func (r *Runner) Fire(command string, info Info, timeout time.Duration) {
+	select {
+	case r.queue <- job{command: command, info: info, timeout: timeout}:
+	default:
+		slog.Warn("Hook queue full, skipping hook", "event", info.Event)
+	}
+}
@@ -0,0 +1,147 @@
+//go:build !windows
+
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeScript writes a shell script to dir and returns a command running it
+func writeScript(t *testing.T, dir, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// waitForFile waits up to a few seconds for path to exist and returns its
+// contents
+func waitForFile(t *testing.T, path string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil && len(data) > 0 {
+			return string(data)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not written", filepath.Base(path))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunPassesEvent(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "env.txt")
+	script := writeScript(t, dir, `echo "$QUADMAX_EVENT|$QUADMAX_SSID|$QUADMAX_ADAPTER|$QUADMAX_SIGNAL|$QUADMAX_PREVIOUS_STATE" > "$1"
+echo "some output"
+`)
+
+	info := Info{Event: EventConnected, SSID: "Quadmax", Adapter: "Wi-Fi 2", Signal: 85, PreviousState: "searching"}
+	if err := Run(script+" "+out, info, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(waitForFile(t, out)); got != "connected|Quadmax|Wi-Fi 2|85|searching" {
+		t.Errorf("hook saw %q", got)
+	}
+
+	info.Signal = -1
+	if env := info.Env(); env[3] != "QUADMAX_SIGNAL=" {
+		t.Errorf("unknown signal passed as %q", env[3])
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	script := writeScript(t, t.TempDir(), "echo failing >&2\nexit 3\n")
+	err := Run(script, Info{Event: EventDisconnected}, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Run = %v, want exit status 3", err)
+	}
+}
+
+func TestRunTimeoutKillsChildren(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	script := writeScript(t, dir, `sleep 30 &
+echo $! > "$1"
+wait
+`)
+
+	start := time.Now()
+	err := Run(script+" "+pidFile, Info{Event: EventConnected}, 300*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Run took %v after the timeout", elapsed)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(waitForFile(t, pidFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("child of the hook survived the timeout")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunLeavesBackgroundProgramsRunning(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "bridge.txt")
+	script := writeScript(t, dir, `(sleep 1; echo running > "$1") &
+echo started
+`)
+
+	start := time.Now()
+	if err := Run(script+" "+out, Info{Event: EventConnected}, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Run waited %v for the background program", elapsed)
+	}
+	if got := strings.TrimSpace(waitForFile(t, out)); got != "running" {
+		t.Errorf("background program wrote %q", got)
+	}
+}
+
+func TestRunnerKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "order.txt")
+	script := writeScript(t, dir, `sleep 0.05
+echo "$QUADMAX_EVENT" >> "$1"
+`)
+
+	r := NewRunner()
+	for _, event := range Events {
+		r.Fire(script+" "+out, Info{Event: event}, 5*time.Second)
+	}
+
+	want := strings.Join(Events, "\n") + "\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(out)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hooks ran as %q, want %q", data, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,102 @@
+package instance
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+const ipcTimeout = 5 * time.Second
+
+// Handler runs the arguments forwarded by a second launch
+type Handler func(args []string) error
+
+type ipcRequest struct {
+	Args []string `json:"args"`
+}
+
+type ipcResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Server receives arguments from later launches of the application. Unix
+// domain sockets are supported on Windows 10 and later, so the same
+// transport is used on every platform.
+type Server struct {
+	listener net.Listener
+	path     string
+}
+
+// Listen starts accepting forwarded arguments on the socket at path. Only
+// the lock owner should call it, as any existing socket file is replaced.
+func Listen(path string, handler Handler) (*Server, error) {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener, path: path}
+	go s.serve(handler)
+	return s, nil
+}
+
+func (s *Server) serve(handler Handler) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go handleConn(conn, handler)
+	}
+}
+
+func handleConn(conn net.Conn, handler Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ipcTimeout))
+
+	var req ipcRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+
+	var resp ipcResponse
+	if err := handler(req.Args); err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Close stops the server and removes the socket file
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// Send forwards args to the running instance listening at path and returns
+// the error it reported, if any
+func Send(path string, args []string) error {
+	conn, err := net.DialTimeout("unix", path, ipcTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ipcTimeout))
+
+	if err := json.NewEncoder(conn).Encode(ipcRequest{Args: args}); err != nil {
+		return err
+	}
+
+	var resp ipcResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyRunning is returned when another live instance holds the lock
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+// How long to wait for a lock whose owner has already exited
+const staleRetryTimeout = 2 * time.Second
+
+// Lock is an OS-level single-instance lock backed by a file. The OS drops
+// the lock when the process dies, so a leftover file never blocks startup.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the single-instance lock at path. It returns
+// ErrAlreadyRunning if a live process holds it.
+func Acquire(path string) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(staleRetryTimeout)
+	for {
+		err = lockFile(file)
+		if err == nil {
+			break
+		}
+
+		// The lock is held. If the PID in the file is alive, it is a real
+		// instance; otherwise the owner is exiting and the OS will release
+		// the lock shortly.
+		pid := readPID(file)
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			file.Close()
+			return nil, fmt.Errorf("%w (pid %d)", ErrAlreadyRunning, pid)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrAlreadyRunning
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Record our PID for other instances and for diagnostics
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+		file.Sync()
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Release frees the lock. The file is left in place so that a new instance
+// never races with its removal.
+func (l *Lock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	l.file.Truncate(0)
+	unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+}
+
+// readPID reads the PID written by the lock owner
+func readPID(file *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,95 @@
+package instance
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// lockChildEnv makes the test binary act as a second instance holding the
+// lock at the given path until its stdin is closed
+const lockChildEnv = "QUADMAX_TEST_LOCK_CHILD"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(lockChildEnv); path != "" {
+		os.Exit(runLockChild(path))
+	}
+	os.Exit(m.Run())
+}
+
+func runLockChild(path string) int {
+	lock, err := Acquire(path)
+	if err != nil {
+		os.Stdout.WriteString("error: " + err.Error() + "\n")
+		return 1
+	}
+	os.Stdout.WriteString("locked\n")
+
+	// Hold the lock until the parent closes stdin
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	_ = lock
+	return 0
+}
+
+func TestAcquireAcrossProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	child.Env = append(os.Environ(), lockChildEnv+"="+path)
+	stdin, err := child.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := child.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer child.Process.Kill()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "locked\n" {
+		t.Fatalf("child did not take the lock: %q, %v", line, err)
+	}
+
+	if lock, err := Acquire(path); !errors.Is(err, ErrAlreadyRunning) {
+		lock.Release()
+		t.Fatalf("Acquire while the child holds the lock = %v, want ErrAlreadyRunning", err)
+	}
+
+	stdin.Close()
+	if err := child.Wait(); err != nil {
+		t.Fatalf("child failed: %v", err)
+	}
+
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire after the child exited: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireReleaseReacquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := readPID(lock.file); pid != os.Getpid() {
+		t.Errorf("lock file holds pid %d, want %d", pid, os.Getpid())
+	}
+	lock.Release()
+	lock.Release() // releasing twice is harmless
+
+	lock, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	lock.Release()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/whenry/quadmax-wifi-connector/instance"
+	"github.com/whenry/quadmax-wifi-connector/notify"
+)
+
+// launchOptions are the arguments a launch can ask the running instance
+// to act on
+type launchOptions struct {
+	openSettings bool
+	connectNow   bool
+	daemon       bool
+
+	// action is a notification action URI, see notify.ActionURI
+	action string
+}
+
+func getSocketPath() string {
+	tempDir := os.TempDir()
+	return filepath.Join(tempDir, "quadmax-wifi-connector.sock")
+}
+
+// parseLaunchArgs parses the launch arguments of this or a later instance
+func parseLaunchArgs(args []string) (launchOptions, error) {
+	var opts launchOptions
+	flags := flag.NewFlagSet("quadmax-wifi-connector", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&opts.openSettings, "settings", false, "open the settings window")
+	flags.BoolVar(&opts.connectNow, "connect", false, "connect to the target network now")
+	flags.BoolVar(&opts.daemon, "daemon", false, "run without the tray, e.g. as a systemd service")
+	flags.StringVar(&opts.action, "action", "", "run a notification action")
+	err := flags.Parse(args)
+	return opts, err
+}
+
+// forwardToRunningInstance hands this launch's arguments to the primary
+// instance. A plain launch opens its settings window.
+func forwardToRunningInstance(args []string) error {
+	if len(args) == 0 {
+		args = []string{"-settings"}
+	}
+	return instance.Send(getSocketPath(), args)
+}
+
+// handleLaunchArgs acts on arguments given at startup or forwarded by a
+// later launch, using the same handlers as the tray menu
+func handleLaunchArgs(args []string) error {
+	opts, err := parseLaunchArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if opts.openSettings {
+		openSettings()
+	}
+	if opts.connectNow {
+		go attemptConnection()
+	}
+	if opts.action != "" {
+		id, ok := notify.ParseActionURI(opts.action)
+		if !ok {
+			return fmt.Errorf("invalid action %q", opts.action)
+		}
+		handleNotificationAction(id)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// journalHandler writes text records prefixed with a "<N>" syslog priority,
+// which journald parses when a service's output goes to the journal
+type journalHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+	inner slog.Handler
+}
+
+func newJournalHandler(w io.Writer, opts *slog.HandlerOptions) *journalHandler {
+	buf := new(bytes.Buffer)
+	textOpts := *opts
+	textOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		// journald records its own timestamp and the priority
+		if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return &journalHandler{
+		w:     w,
+		mu:    new(sync.Mutex),
+		buf:   buf,
+		inner: slog.NewTextHandler(buf, &textOpts),
+	}
+}
+
+func (h *journalHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *journalHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(h.w, "<%d>%s", priority(r.Level), h.buf.Bytes())
+	return err
+}
+
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &journalHandler{w: h.w, mu: h.mu, buf: h.buf, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	return &journalHandler{w: h.w, mu: h.mu, buf: h.buf, inner: h.inner.WithGroup(name)}
+}
+
+// priority maps a slog level to a syslog priority
+func priority(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+)
+
+const (
+	logDir  = "logs"
+	logFile = "quadmax-wifi-connector.log"
+)
+
+var (
+	// level is shared by all handlers so it can be changed at runtime
+	level = new(slog.LevelVar)
+
+	file *rotatingFile
+)
+
+// Options configures Setup
+type Options struct {
+	Level slog.Level
+	// Journal also writes to stdout with journald priority prefixes
+	Journal bool
+}
+
+// Dir returns the directory holding the log files
+func Dir() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, logDir), nil
+}
+
+// Setup installs the default slog logger, writing to a rotating file in
+// the log directory. If the file cannot be opened, logging still goes to
+// stderr or the journal and the error is returned.
+func Setup(opts Options) error {
+	level.Set(opts.Level)
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handlers []slog.Handler
+	if opts.Journal {
+		handlers = append(handlers, newJournalHandler(os.Stdout, handlerOpts))
+	}
+
+	var err error
+	file, err = openLogFile()
+	if err == nil {
+		handlers = append(handlers, slog.NewTextHandler(file, handlerOpts))
+	} else if !opts.Journal {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, handlerOpts))
+	}
+
+	slog.SetDefault(slog.New(fanoutHandler(handlers)))
+	return err
+}
+
+// Close closes the log file
+func Close() error {
+	if file == nil {
+		return nil
+	}
+	return file.Close()
+}
+
+func openLogFile() (*rotatingFile, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return openRotatingFile(filepath.Join(dir, logFile), maxLogSize, maxLogBackups)
+}
+
+// SetLevel changes the level of the installed logger
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// ParseLevel converts a config level name to a slog level, defaulting to
+// info for unknown names
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// fanoutHandler sends each record to every handler that accepts its level
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
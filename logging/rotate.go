@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	maxLogSize    = 5 * 1024 * 1024
+	maxLogBackups = 3
+)
+
+// rotatingFile is a log file that is renamed to path.1, path.2, ... once it
+// reaches maxSize, keeping at most backups old files
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+	closed  bool
+
+	// failed is set while the file cannot be opened, so the error is
+	// reported once rather than on every write
+	failed bool
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.file == nil {
+		if err := r.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups up by one and starts a new file
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.backups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+
+	return r.reopen()
+}
+
+// reopen opens the log file after a rotation or a failed open, reporting
+// failures on stderr since they cannot be logged. Writes retry until the
+// file can be opened again.
+func (r *rotatingFile) reopen() error {
+	if err := r.open(); err != nil {
+		if !r.failed {
+			fmt.Fprintf(os.Stderr, "Could not open log file %s: %v\n", r.path, err)
+			r.failed = true
+		}
+		return err
+	}
+	if r.failed {
+		fmt.Fprintf(os.Stderr, "Log file %s reopened\n", r.path)
+		r.failed = false
+	}
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
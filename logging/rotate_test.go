@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, want := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(file), data, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("more backups kept than configured")
+	}
+}
+
+func TestRotatingFileRecoversFromFailedOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.log")
+	r, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rotation cannot reopen the file while its directory is missing
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("lost line\n")); err == nil {
+		t.Fatal("write succeeded without a log directory")
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("recovered\n")); err != nil {
+		t.Fatalf("write after the directory came back: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "recovered\n" {
+		t.Errorf("log file = %q, want the recovered line", data)
+	}
+
+	r.Close()
+	if _, err := r.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("write after Close = %v, want os.ErrClosed", err)
+	}
+}
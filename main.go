@@ -1,21 +1,53 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getlantern/systray"
-	"github.com/go-toast/toast"
 
+	"github.com/whenry/quadmax-wifi-connector/api"
 	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/dashboard"
+	"github.com/whenry/quadmax-wifi-connector/diagnostics"
+	"github.com/whenry/quadmax-wifi-connector/events"
+	"github.com/whenry/quadmax-wifi-connector/history"
+	"github.com/whenry/quadmax-wifi-connector/hooks"
 	"github.com/whenry/quadmax-wifi-connector/icons"
+	"github.com/whenry/quadmax-wifi-connector/instance"
+	"github.com/whenry/quadmax-wifi-connector/logging"
+	"github.com/whenry/quadmax-wifi-connector/metrics"
+	"github.com/whenry/quadmax-wifi-connector/notify"
 	"github.com/whenry/quadmax-wifi-connector/ui"
+	"github.com/whenry/quadmax-wifi-connector/webhook"
 	"github.com/whenry/quadmax-wifi-connector/wifi"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+var (
+	errNoTargetNetwork    = errors.New("no target network configured")
+	errVerificationFailed = errors.New("connection verification failed")
+)
+
+// signalSampleInterval is how often the signal is recorded in the history
+// while connected
+const signalSampleInterval = 2 * time.Minute
+
+// reachTimeout limits the ping of the Quadmax made on every poll
+const reachTimeout = 3 * time.Second
+
 // ConnectionState represents the current connection state
 type ConnectionState int
 
@@ -25,35 +57,167 @@ const (
 	StateConnected
 )
 
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateSearching:
+		return "searching"
+	default:
+		return "disconnected"
+	}
+}
+
 var (
-	cfg           *config.Config
-	cfgMutex      sync.RWMutex
-	currentState  ConnectionState
-	stateMutex    sync.RWMutex
-	stopPolling   chan struct{}
-	mStatusItem   *systray.MenuItem
-	lockFile      *os.File
+	cfg               *config.Config
+	cfgMutex          sync.RWMutex
+	currentState      ConnectionState
+	currentStatusText string
+	stateRecorded     bool
+	everConnected     bool
+	stateMutex        sync.RWMutex
+	stopPolling       chan struct{}
+	mStatusItem       *systray.MenuItem
+	mPauseItem        *systray.MenuItem
+	ipcServer         *instance.Server
+	apiServer         *api.Server
+	historyStore      *history.Store
+	metricsServer     *metrics.Server
+
+	notifier      notify.Notifier
+	notifierKind  string
+	notifierMutex sync.Mutex
+
+	// notifyPolicy filters notifications about connection events, it is
+	// configured by applyConfig
+	notifyPolicy    = notify.NewPolicy(notify.PolicyOptions{}, showEventNotification)
+	lowSignalWarned bool
+
+	// lastSignalSample is when the signal was last recorded in the history
+	lastSignalSample time.Time
+
+	// targetChanged is set when a new target network is configured, until
+	// the polling loop acts on it
+	targetChanged atomic.Bool
+
+	// deviceReachable is whether the Quadmax answered the last poll's ping
+	deviceReachable atomic.Bool
+
+	hookRunner = hooks.NewRunner()
+
+	// fireHook queues a hook command, tests replace it to see the hooks
+	fireHook = hookRunner.Fire
+
+	// hookConnected is set once the connected hook ran, until the
+	// disconnected hook runs, so the two always come in pairs
+	hookConnected bool
+
+	webhooks    *webhook.Dispatcher
+	hostname, _ = os.Hostname()
+
+	// Auto-connect is paused until pausedUntil, or indefinitely if paused
+	// is set with a zero time
+	paused      bool
+	pausedUntil time.Time
+	pauseMutex  sync.Mutex
+
+	// previousNetwork is the network the adapter was on before we switched
+	// it to the target, and targetMissingSince is when the target was last
+	// seen going out of range
+	previousNetwork    string
+	targetMissingSince time.Time
+	restoreMutex       sync.Mutex
+
+	// Dual-adapter mode status
+	mInternetItem       *systray.MenuItem
+	sameNetworkWarned   bool
+	internetStatusText  string
+	internetStatusMutex sync.Mutex
 )
 
 func main() {
+	// Headless subcommands run without the tray or a lock
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		os.Exit(runCLI(os.Args[1], os.Args[2:]))
+	}
+
+	opts, err := parseLaunchArgs(os.Args[1:])
+	if err != nil {
+		attachConsole()
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return
+		}
+		fmt.Printf("Invalid arguments: %v\n", err)
+		printUsage(os.Stdout)
+		os.Exit(exitUsage)
+	}
+
 	// Check for single instance
-	if !acquireLock() {
-		showNotification("Already Running", "Quadmax WiFi Connector is already running.")
-		fmt.Println("Quadmax WiFi Connector is already running.")
+	lock, err := instance.Acquire(getLockFilePath())
+	if err != nil {
+		if opts.daemon {
+			fmt.Printf("<3>Could not start: %v\n", err)
+			os.Exit(exitError)
+		}
+		if errors.Is(err, instance.ErrAlreadyRunning) {
+			// Let the running instance handle this launch
+			if err := forwardToRunningInstance(os.Args[1:]); err == nil {
+				return
+			}
+			showNotification("Already Running", "Quadmax WiFi Connector is already running.")
+		}
+		fmt.Printf("Quadmax WiFi Connector could not start: %v\n", err)
 		return
 	}
-	defer releaseLock()
+	defer lock.Release()
 
-	// Initialize UI before systray
-	ui.InitApp()
+	// Set before the config is applied so the notifier is chosen for it
+	daemonMode = opts.daemon
+
+	// Log at info until the config says otherwise
+	if err := logging.Setup(logging.Options{Level: slog.LevelInfo, Journal: opts.daemon}); err != nil {
+		slog.Warn("Could not open log file", "error", err)
+	}
+	defer logging.Close()
 
 	// Load configuration
-	var err error
-	cfg, err = config.Load()
+	loaded, err := config.Load()
+	if err != nil {
+		slog.Warn("Could not load config", "error", err)
+	}
+	notify.SetActionHandler(handleNotificationAction)
+	applyConfig(loaded)
+	wifi.SetCommandObserver(metrics.ObserveNetsh)
+
+	historyStore, err = history.OpenDefault()
 	if err != nil {
-		fmt.Printf("Warning: Could not load config: %v\n", err)
+		slog.Warn("Could not open connection history", "error", err)
+	}
+	defer recordHistory(history.StateStopped, "Exited", nil, false)
+
+	if path, err := webhook.DefaultPath(); err == nil {
+		webhooks = webhook.Open(path)
+		applyWebhookTargets(loaded)
+		defer webhooks.Close()
+	} else {
+		slog.Warn("Could not start webhooks", "error", err)
+	}
+
+	if opts.daemon {
+		runDaemon()
+		return
 	}
 
+	slog.Info("Starting")
+
+	// Initialize UI before systray
+	ui.InitApp()
+	ui.SetDiagnosticsHandler(func() (string, error) {
+		return createDiagnostics("")
+	})
+	ui.SetConnectHandler(connectToNetwork)
+
 	// Run Fyne event loop in background (required for windows to work)
 	go ui.RunApp()
 
@@ -66,35 +230,6 @@ func getLockFilePath() string {
 	return filepath.Join(tempDir, "quadmax-wifi-connector.lock")
 }
 
-func acquireLock() bool {
-	lockPath := getLockFilePath()
-	var err error
-	lockFile, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
-	if err != nil {
-		if os.IsExist(err) {
-			// Lock file exists, check if process is still running
-			// Try to remove stale lock and retry
-			os.Remove(lockPath)
-			lockFile, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
-			if err != nil {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-	// Write PID to lock file
-	fmt.Fprintf(lockFile, "%d", os.Getpid())
-	return true
-}
-
-func releaseLock() {
-	if lockFile != nil {
-		lockFile.Close()
-		os.Remove(getLockFilePath())
-	}
-}
-
 func onReady() {
 	// Set initial icon
 	systray.SetIcon(icons.IconDisconnected)
@@ -105,36 +240,115 @@ func onReady() {
 	mStatusItem = systray.AddMenuItem("Status: Initializing...", "Current connection status")
 	mStatusItem.Disable()
 
+	mInternetItem = systray.AddMenuItem("Internet: Initializing...", "Internet adapter status")
+	mInternetItem.Disable()
+	mInternetItem.Hide()
+
 	systray.AddSeparator()
 
 	mSettings := systray.AddMenuItem("Settings...", "Open settings window")
+	mWizard := systray.AddMenuItem("Setup Wizard...", "Run the first-run setup again")
+	mHistory := systray.AddMenuItem("Connection History...", "Show the connection timeline")
+	mDashboard := systray.AddMenuItem("Open Web Dashboard", "Open the dashboard in a browser")
+	mDashboard.Hide()
 	mConnect := systray.AddMenuItem("Connect Now", "Attempt to connect immediately")
+	mDisconnect := systray.AddMenuItem("Disconnect", "Disconnect and pause auto-connect")
+	mPauseItem = systray.AddMenuItem("Pause Auto-Connect", "Stop connecting automatically until resumed")
 
 	systray.AddSeparator()
 
+	mLogs := systray.AddMenuItem("Open Log Folder", "Show the log files")
+	mDiagnostics := systray.AddMenuItem("Create Diagnostics Bundle", "Collect logs and settings for support")
 	mQuit := systray.AddMenuItem("Exit", "Quit the application")
 
 	// Start the polling goroutine
 	stopPolling = make(chan struct{})
 	go pollWiFi()
 
+	// Accept arguments from later launches
+	var err error
+	ipcServer, err = instance.Listen(getSocketPath(), handleLaunchArgs)
+	if err != nil {
+		slog.Warn("Could not listen for other launches", "error", err)
+	}
+	handleLaunchArgs(os.Args[1:])
+
+	// The config file is written once setup is finished or skipped
+	if !config.Exists() {
+		openWizard()
+	}
+
+	apiServer, err = startControlAPI()
+	if err != nil {
+		slog.Warn("Could not start control API", "error", err)
+	}
+	metricsServer, err = startMetrics()
+	if err != nil {
+		slog.Warn("Could not start metrics endpoint", "error", err)
+	}
+	mqttClient, err = startMQTT()
+	if err != nil {
+		slog.Warn("Could not start MQTT client", "error", err)
+	}
+	eventServer, err = startEventStream()
+	if err != nil {
+		slog.Warn("Could not start event stream", "error", err)
+	}
+	dashboardServer, err = startDashboard()
+	if err != nil {
+		slog.Warn("Could not start dashboard", "error", err)
+	}
+	if dashboardServer != nil {
+		mDashboard.Show()
+	}
+
 	// Handle menu clicks
 	go func() {
 		for {
 			select {
 			case <-mSettings.ClickedCh:
+				openSettings()
+
+			case <-mWizard.ClickedCh:
+				openWizard()
+
+			case <-mHistory.ClickedCh:
+				if historyStore != nil {
+					ui.ShowHistory(historyStore)
+				}
+
+			case <-mDashboard.ClickedCh:
 				cfgMutex.RLock()
-				currentCfg := *cfg
+				port := cfg.DashboardPort
 				cfgMutex.RUnlock()
-				ui.ShowSettings(&currentCfg, func(newCfg *config.Config) {
-					cfgMutex.Lock()
-					cfg = newCfg
-					cfgMutex.Unlock()
-				})
+				openPath(dashboard.URL(port))
 
 			case <-mConnect.ClickedCh:
 				go attemptConnection()
 
+			case <-mDisconnect.ClickedCh:
+				go disconnectNow()
+
+			case <-mPauseItem.ClickedCh:
+				if isPaused() {
+					resumeAutoConnect()
+				} else {
+					pauseAutoConnect(0)
+				}
+
+			case <-mLogs.ClickedCh:
+				openLogFolder()
+
+			case <-mDiagnostics.ClickedCh:
+				go func() {
+					if path, err := createDiagnostics(""); err == nil {
+						showNotification("Diagnostics Bundle Created", path)
+						openPath(filepath.Dir(path))
+					} else {
+						showNotification("Diagnostics Failed", err.Error())
+					}
+				}()
+
 			case <-mQuit.ClickedCh:
 				systray.Quit()
 				return
@@ -146,123 +360,881 @@ func onReady() {
 func onExit() {
 	// Stop the polling goroutine
 	close(stopPolling)
+	if ipcServer != nil {
+		ipcServer.Close()
+	}
+	if apiServer != nil {
+		apiServer.Close()
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if mqttClient != nil {
+		mqttClient.Close()
+	}
+	if eventServer != nil {
+		eventServer.Close()
+	}
+	if dashboardServer != nil {
+		dashboardServer.Close()
+	}
 	ui.QuitApp()
 }
 
+// startMetrics serves the metrics endpoint if it is enabled in the config
+func startMetrics() (*metrics.Server, error) {
+	cfgMutex.RLock()
+	enabled := cfg.MetricsEnabled
+	port := cfg.MetricsPort
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		return nil, nil
+	}
+	return metrics.Start(port)
+}
+
+// openSettings shows the settings window for the current config
+func openSettings() {
+	cfgMutex.RLock()
+	currentCfg := cfg.Clone()
+	cfgMutex.RUnlock()
+	ui.ShowSettings(currentCfg, historyStore, applyConfig)
+}
+
+// openWizard shows the setup wizard. Auto-connect is paused while it is
+// open so the polling loop does not get in the way of its connection test.
+func openWizard() {
+	cfgMutex.RLock()
+	currentCfg := cfg.Clone()
+	cfgMutex.RUnlock()
+
+	wasPaused := isPaused()
+	opened := ui.ShowWizard(currentCfg, applyConfig, func() {
+		if !wasPaused {
+			resumeAutoConnect()
+		}
+	})
+	if opened && !wasPaused {
+		pauseAutoConnect(0)
+	}
+}
+
+// applyConfig makes newCfg the active config
+func applyConfig(newCfg *config.Config) {
+	cfgMutex.Lock()
+	if cfg != nil && cfg.SelectedNetwork != newCfg.SelectedNetwork {
+		targetChanged.Store(true)
+	}
+	cfg = newCfg
+	cfgMutex.Unlock()
+
+	logging.SetLevel(logging.ParseLevel(newCfg.LogLevel))
+	setNotifier(newCfg.Notifier)
+	applyWebhookTargets(newCfg)
+	events.SetFile(newCfg.StatusFile)
+	notifyPolicy.SetOptions(policyOptions(newCfg))
+}
+
+// policyOptions returns the notification policy described by c
+func policyOptions(c *config.Config) notify.PolicyOptions {
+	opts := notify.PolicyOptions{
+		Enabled: map[notify.Event]bool{
+			notify.EventConnected:      c.NotifyConnected,
+			notify.EventDisconnected:   c.NotifyDisconnected,
+			notify.EventFailed:         c.NotifyFailed,
+			notify.EventLowSignal:      c.NotifyLowSignal,
+			notify.EventAdapterMissing: c.NotifyAdapterMissing,
+			notify.EventSameNetwork:    c.NotifySameNetwork,
+			notify.EventRestored:       c.NotifyRestored,
+		},
+		DedupWindow: time.Duration(c.NotifyDedupWindow) * time.Second,
+		FlapWindow:  time.Duration(c.NotifyFlapWindow) * time.Second,
+	}
+
+	start, startErr := notify.ParseClock(c.QuietHoursStart)
+	end, endErr := notify.ParseClock(c.QuietHoursEnd)
+	if c.QuietHours && startErr == nil && endErr == nil {
+		opts.QuietHours = true
+		opts.QuietStart = start
+		opts.QuietEnd = end
+	}
+	return opts
+}
+
+// createDiagnostics writes a diagnostics bundle to path, or to a new file
+// in the config directory if path is empty, and returns its path
+func createDiagnostics(path string) (string, error) {
+	if path == "" {
+		var err error
+		if path, err = diagnostics.DefaultPath(); err != nil {
+			return "", err
+		}
+	}
+
+	cfgMutex.RLock()
+	opts := diagnostics.Options{
+		Version: version,
+		Adapter: cfg.SelectedAdapter,
+		Network: cfg.SelectedNetwork,
+	}
+	cfgMutex.RUnlock()
+
+	slog.Info("Creating diagnostics bundle", "path", path)
+	if err := diagnostics.CreateBundle(path, opts); err != nil {
+		slog.Error("Could not create diagnostics bundle", "error", err)
+		return "", err
+	}
+	return path, nil
+}
+
+// openLogFolder shows the log directory in the file manager
+func openLogFolder() {
+	dir, err := logging.Dir()
+	if err != nil {
+		slog.Warn("Could not find log folder", "error", err)
+		return
+	}
+	if err := openPath(dir); err != nil {
+		slog.Warn("Could not open log folder", "path", dir, "error", err)
+	}
+}
+
+// openPath opens a file or folder with the desktop's default application
+func openPath(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer", path)
+	case "darwin":
+		cmd = exec.Command("open", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
+
 func pollWiFi() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(pollInterval())
 	defer ticker.Stop()
 
 	// Initial check
-	checkAndConnect()
+	poll()
 
 	for {
 		select {
 		case <-ticker.C:
-			checkAndConnect()
+			poll()
+			ticker.Reset(pollInterval())
 		case <-stopPolling:
 			return
 		}
 	}
 }
 
-func checkAndConnect() {
+// poll runs one round of connection checks
+func poll() {
+	status := checkAndConnect()
+	checkDeviceReachable(status)
+	checkInternetAdapter(status)
+	lastPoll.Store(time.Now().UnixNano())
+}
+
+// checkDeviceReachable pings the Quadmax while connected, for the MQTT
+// state, and publishes the result when it changes. status is the adapter
+// status read by checkAndConnect, or nil if it is unknown.
+func checkDeviceReachable(status *wifi.ConnectionStatus) {
+	reachable := false
+	if state, _ := getState(); state == StateConnected && mqttClient != nil {
+		cfgMutex.RLock()
+		adapter := cfg.SelectedAdapter
+		cfgMutex.RUnlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), reachTimeout)
+		_, err := wifi.CheckReachable(ctx, adapter)
+		cancel()
+		if err != nil {
+			slog.Debug("Quadmax not reachable", "adapter", adapter, "error", err)
+		}
+		reachable = err == nil
+	}
+
+	if deviceReachable.Swap(reachable) != reachable {
+		publishStatus(status)
+	}
+}
+
+// pollInterval returns the configured time between connection checks
+func pollInterval() time.Duration {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
+	return time.Duration(cfg.PollInterval) * time.Second
+}
+
+// checkAndConnect connects the adapter to the target network if needed. It
+// returns the adapter status it last read, or nil if it is unknown.
+func checkAndConnect() *wifi.ConnectionStatus {
 	cfgMutex.RLock()
 	adapter := cfg.SelectedAdapter
 	targetNetwork := cfg.SelectedNetwork
 	cfgMutex.RUnlock()
 
+	// Leaving the old target after the user picked a new one is not a drop
+	updateState := updateState
+	if targetChanged.Swap(false) {
+		updateState = updateStateOnRequest
+	}
+
 	// If no network is configured, show disconnected state
 	if targetNetwork == "" {
-		updateState(StateDisconnected, "No network configured")
-		return
+		updateState(StateDisconnected, "No network configured", nil)
+		return nil
 	}
 
 	// Check current connection status
 	status, err := wifi.GetConnectionStatus(adapter)
 	if err != nil {
-		updateState(StateDisconnected, "Error checking status")
-		return
+		slog.Warn("Could not check connection status", "adapter", adapter, "error", err)
+		noteError("status", err)
+		updateState(StateDisconnected, "Error checking status", nil)
+		return nil
+	}
+
+	if adapter != "" && status.AdapterName == "" {
+		previous, previousText := getState()
+		text := fmt.Sprintf("Adapter %s not found", adapter)
+		updateState(StateDisconnected, text, nil)
+		if text != previousText {
+			runHook(hooks.EventAdapterMissing, previous, nil)
+		}
+		notifyPolicy.Notify(notify.EventAdapterMissing, "Adapter Missing", fmt.Sprintf("The WiFi adapter %s was not found", adapter))
+		return nil
 	}
 
 	// Already connected to target network
 	if status.Connected && status.SSID == targetNetwork {
-		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork))
-		return
+		markTargetSeen()
+		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork), status)
+		return status
+	}
+
+	// Leave the adapter alone while auto-connect is paused
+	if isPaused() {
+		updateState(StateDisconnected, "Auto-connect paused", status)
+		return status
 	}
 
 	// Check if target network is available
 	available, err := wifi.IsNetworkAvailable(adapter, targetNetwork)
 	if err != nil {
-		updateState(StateDisconnected, "Error scanning networks")
-		return
+		slog.Warn("Could not scan networks", "adapter", adapter, "error", err)
+		noteError("scan", err)
+		updateState(StateDisconnected, "Error scanning networks", status)
+		return status
 	}
 
 	if !available {
-		updateState(StateDisconnected, fmt.Sprintf("%s not in range", targetNetwork))
-		return
+		previous, previousText := getState()
+		text := fmt.Sprintf("%s not in range", targetNetwork)
+		updateState(StateDisconnected, text, status)
+		if text != previousText {
+			runHook(hooks.EventNotInRange, previous, status)
+		}
+		restorePreviousNetwork(adapter, targetNetwork, status)
+		return status
 	}
+	markTargetSeen()
 
 	// Network is available but not connected - attempt to connect
-	updateState(StateSearching, fmt.Sprintf("Connecting to %s...", targetNetwork))
+	updateState(StateSearching, fmt.Sprintf("Connecting to %s...", targetNetwork), status)
+
+	rememberPreviousNetwork(targetNetwork, status)
+	connectStart := time.Now()
+	err = connectWithTimeout(adapter, targetNetwork)
+	if err != nil {
+		slog.Warn("Could not connect", "adapter", adapter, "network", targetNetwork, "error", err)
+		noteError("connect", err)
+		updateState(StateDisconnected, "Connection failed", nil)
+		runHook(hooks.EventConnectFailed, StateSearching, nil)
+		notifyPolicy.Notify(notify.EventFailed, "Connection Failed", fmt.Sprintf("Could not connect to %s", targetNetwork))
+		return nil
+	}
+
+	// Wait for the connection to come up
+	status, ok := waitForConnection(adapter, targetNetwork)
+	if ok {
+		metrics.ObserveConnect(time.Since(connectStart))
+		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork), status)
+	} else {
+		noteError("verify", errVerificationFailed)
+		updateState(StateDisconnected, "Connection verification failed", nil)
+		runHook(hooks.EventConnectFailed, StateSearching, nil)
+	}
+	return status
+}
+
+// checkInternetAdapter keeps the internet adapter connected to its network
+// when dual-adapter mode is enabled. quadmaxStatus is the Quadmax adapter
+// status read by checkAndConnect, or nil if it is unknown.
+func checkInternetAdapter(quadmaxStatus *wifi.ConnectionStatus) {
+	cfgMutex.RLock()
+	enabled := cfg.DualAdapterMode
+	quadmaxAdapter := cfg.SelectedAdapter
+	adapter := cfg.InternetAdapter
+	network := cfg.InternetNetwork
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		if mInternetItem != nil {
+			mInternetItem.Hide()
+		}
+		return
+	}
+	if mInternetItem != nil {
+		mInternetItem.Show()
+	}
+
+	if adapter == "" || network == "" {
+		setInternetStatus("No adapter configured")
+		return
+	}
+	if quadmaxAdapter == "" || adapter == quadmaxAdapter {
+		setInternetStatus("Select two different adapters")
+		return
+	}
 
-	err = wifi.Connect(adapter, targetNetwork)
+	status, err := wifi.GetConnectionStatus(adapter)
 	if err != nil {
-		updateState(StateDisconnected, "Connection failed")
-		showNotification("Connection Failed", fmt.Sprintf("Could not connect to %s", targetNetwork))
+		setInternetStatus("Error checking status")
 		return
 	}
 
-	// Wait a moment and verify connection
-	time.Sleep(2 * time.Second)
+	if !status.Connected || status.SSID != network {
+		// Leave the adapter alone while auto-connect is paused
+		if isPaused() {
+			setInternetStatus("Auto-connect paused")
+			return
+		}
+
+		available, err := wifi.IsNetworkAvailable(adapter, network)
+		if err != nil {
+			setInternetStatus("Error scanning networks")
+			return
+		}
+		if !available {
+			setInternetStatus(fmt.Sprintf("%s not in range", network))
+			return
+		}
 
-	status, err = wifi.GetConnectionStatus(adapter)
-	if err == nil && status.Connected && status.SSID == targetNetwork {
-		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork))
-		showNotification("Connected", fmt.Sprintf("Successfully connected to %s", targetNetwork))
+		setInternetStatus(fmt.Sprintf("Connecting to %s...", network))
+		if err := connectWithTimeout(adapter, network); err != nil {
+			setInternetStatus("Connection failed")
+			return
+		}
+
+		var ok bool
+		if status, ok = waitForConnection(adapter, network); !ok {
+			setInternetStatus("Connection verification failed")
+			return
+		}
+	}
+
+	if checkSameNetwork(quadmaxStatus, status) {
+		setInternetStatus(fmt.Sprintf("Both adapters on %s", status.SSID))
 	} else {
-		updateState(StateDisconnected, "Connection verification failed")
+		setInternetStatus(fmt.Sprintf("Connected to %s", network))
 	}
 }
 
-func attemptConnection() {
+// setInternetStatus shows the internet adapter status in the tray and logs
+// changes
+func setInternetStatus(text string) {
+	internetStatusMutex.Lock()
+	changed := internetStatusText != text
+	internetStatusText = text
+	internetStatusMutex.Unlock()
+	if changed {
+		slog.Info("Internet adapter status changed", "status", text)
+	}
+
+	if mInternetItem != nil {
+		mInternetItem.SetTitle("Internet: " + text)
+	}
+}
+
+// checkSameNetwork reports whether both adapters are on the same network,
+// warning when that starts. An unknown Quadmax status keeps the last result.
+func checkSameNetwork(quadmaxStatus, internetStatus *wifi.ConnectionStatus) bool {
+	if quadmaxStatus == nil {
+		return sameNetworkWarned
+	}
+
+	same := quadmaxStatus.Connected && internetStatus.Connected && quadmaxStatus.SSID == internetStatus.SSID
+	if same && !sameNetworkWarned {
+		notifyPolicy.Notify(notify.EventSameNetwork, "Same Network", fmt.Sprintf("Both adapters are connected to %s. Check your adapter settings.", internetStatus.SSID))
+	}
+	sameNetworkWarned = same
+	return same
+}
+
+// attemptConnection connects to the target network immediately
+func attemptConnection() error {
 	cfgMutex.RLock()
 	adapter := cfg.SelectedAdapter
 	targetNetwork := cfg.SelectedNetwork
 	cfgMutex.RUnlock()
 
 	if targetNetwork == "" {
-		showNotification("Error", "No target network configured. Open Settings to configure.")
-		return
+		notifyPolicy.Notify(notify.EventFailed, "Error", "No target network configured. Open Settings to configure.")
+		return errNoTargetNetwork
+	}
+
+	// An explicit connect ends any pause
+	if isPaused() {
+		resumeAutoConnect()
 	}
 
-	updateState(StateSearching, fmt.Sprintf("Connecting to %s...", targetNetwork))
+	updateStateOnRequest(StateSearching, fmt.Sprintf("Connecting to %s...", targetNetwork), nil)
+
+	if status, err := wifi.GetConnectionStatus(adapter); err == nil {
+		rememberPreviousNetwork(targetNetwork, status)
+	}
 
-	err := wifi.Connect(adapter, targetNetwork)
+	slog.Info("Connecting on request", "adapter", adapter, "network", targetNetwork)
+	connectStart := time.Now()
+	err := connectWithTimeout(adapter, targetNetwork)
 	if err != nil {
-		updateState(StateDisconnected, "Connection failed")
-		showNotification("Connection Failed", fmt.Sprintf("Could not connect to %s", targetNetwork))
+		slog.Warn("Could not connect", "adapter", adapter, "network", targetNetwork, "error", err)
+		noteError("connect", err)
+		updateState(StateDisconnected, "Connection failed", nil)
+		runHook(hooks.EventConnectFailed, StateSearching, nil)
+		notifyPolicy.Notify(notify.EventFailed, "Connection Failed", fmt.Sprintf("Could not connect to %s", targetNetwork))
+		return err
+	}
+
+	// Wait for the connection to come up
+	if status, ok := waitForConnection(adapter, targetNetwork); ok {
+		metrics.ObserveConnect(time.Since(connectStart))
+		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork), status)
+		return nil
+	}
+
+	noteError("verify", errVerificationFailed)
+	updateState(StateDisconnected, "Connection verification failed", nil)
+	runHook(hooks.EventConnectFailed, StateSearching, nil)
+	return errVerificationFailed
+}
+
+// connectWithTimeout connects adapter to network, giving up after the
+// configured connect timeout
+func connectWithTimeout(adapter, network string) error {
+	cfgMutex.RLock()
+	timeout := time.Duration(cfg.ConnectTimeout) * time.Second
+	cfgMutex.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return wifi.ConnectContext(ctx, adapter, network)
+}
+
+// waitForConnection polls adapter until it is connected to network or the
+// configured verification deadline passes
+func waitForConnection(adapter, network string) (*wifi.ConnectionStatus, bool) {
+	cfgMutex.RLock()
+	deadline := time.Now().Add(time.Duration(cfg.VerifyDeadline) * time.Second)
+	cfgMutex.RUnlock()
+
+	for {
+		time.Sleep(time.Second)
+		status, err := wifi.GetConnectionStatus(adapter)
+		if err == nil && status.Connected && status.SSID == network {
+			return status, true
+		}
+		if time.Now().After(deadline) {
+			return status, false
+		}
+	}
+}
+
+// disconnectNow disconnects the adapter and pauses auto-connect so the
+// polling loop does not immediately reconnect
+func disconnectNow() error {
+	cfgMutex.RLock()
+	adapter := cfg.SelectedAdapter
+	cfgMutex.RUnlock()
+
+	slog.Info("Disconnecting on request", "adapter", adapter)
+	pauseAutoConnect(0)
+	if err := wifi.Disconnect(adapter); err != nil {
+		slog.Warn("Could not disconnect", "adapter", adapter, "error", err)
+		noteError("disconnect", err)
+		return err
+	}
+
+	updateStateOnRequest(StateDisconnected, "Disconnected", nil)
+	return nil
+}
+
+// connectToNetwork connects to a saved network picked by the user. Any
+// network but the target pauses auto-connect, which would otherwise switch
+// straight back.
+func connectToNetwork(network string) error {
+	cfgMutex.RLock()
+	adapter := cfg.SelectedAdapter
+	targetNetwork := cfg.SelectedNetwork
+	cfgMutex.RUnlock()
+
+	if network == targetNetwork {
+		return attemptConnection()
+	}
+
+	slog.Info("Connecting to another network on request", "adapter", adapter, "network", network)
+	pauseAutoConnect(0)
+	updateStateOnRequest(StateDisconnected, "Auto-connect paused", nil)
+	if err := wifi.Connect(adapter, network); err != nil {
+		slog.Warn("Could not connect", "adapter", adapter, "network", network, "error", err)
+		noteError("connect", err)
+		return err
+	}
+	return nil
+}
+
+// pauseAutoConnect stops automatic connection attempts for d, or until
+// resumed if d is zero
+func pauseAutoConnect(d time.Duration) {
+	pauseMutex.Lock()
+	paused = true
+	pausedUntil = time.Time{}
+	if d > 0 {
+		pausedUntil = time.Now().Add(d)
+	}
+	pauseMutex.Unlock()
+
+	slog.Info("Auto-connect paused", "duration", d)
+	metrics.SetPaused(true)
+	if mPauseItem != nil {
+		mPauseItem.SetTitle("Resume Auto-Connect")
+	}
+	publishStatus(nil)
+}
+
+// resumeAutoConnect re-enables automatic connection attempts
+func resumeAutoConnect() {
+	pauseMutex.Lock()
+	paused = false
+	pausedUntil = time.Time{}
+	pauseMutex.Unlock()
+
+	slog.Info("Auto-connect resumed")
+	metrics.SetPaused(false)
+	if mPauseItem != nil {
+		mPauseItem.SetTitle("Pause Auto-Connect")
+	}
+	publishStatus(nil)
+}
+
+// isPaused reports whether auto-connect is paused, resuming it once a
+// timed pause has expired
+func isPaused() bool {
+	pauseMutex.Lock()
+	expired := paused && !pausedUntil.IsZero() && time.Now().After(pausedUntil)
+	current := paused
+	pauseMutex.Unlock()
+
+	if expired {
+		resumeAutoConnect()
+		return false
+	}
+	return current
+}
+
+// pauseStatus returns whether auto-connect is paused and until when
+func pauseStatus() (bool, time.Time) {
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	return paused, pausedUntil
+}
+
+// rememberPreviousNetwork records the network the adapter is on before we
+// switch it to the target, so it can be restored later
+func rememberPreviousNetwork(targetNetwork string, status *wifi.ConnectionStatus) {
+	if !status.Connected || status.SSID == "" || status.SSID == targetNetwork {
 		return
 	}
 
-	// Wait and verify
-	time.Sleep(2 * time.Second)
+	restoreMutex.Lock()
+	previousNetwork = status.SSID
+	restoreMutex.Unlock()
+}
 
-	status, err := wifi.GetConnectionStatus(adapter)
-	if err == nil && status.Connected && status.SSID == targetNetwork {
-		updateState(StateConnected, fmt.Sprintf("Connected to %s", targetNetwork))
-		showNotification("Connected", fmt.Sprintf("Successfully connected to %s", targetNetwork))
-	} else {
-		updateState(StateDisconnected, "Connection verification failed")
+// markTargetSeen resets the out-of-range timer for the target network
+func markTargetSeen() {
+	restoreMutex.Lock()
+	targetMissingSince = time.Time{}
+	restoreMutex.Unlock()
+}
+
+// restorePreviousNetwork reconnects to the previous network once the target
+// has been out of range for the configured grace period
+func restorePreviousNetwork(adapter, targetNetwork string, status *wifi.ConnectionStatus) {
+	cfgMutex.RLock()
+	enabled := cfg.RestorePreviousNetwork
+	gracePeriod := time.Duration(cfg.RestoreGracePeriod) * time.Second
+	cfgMutex.RUnlock()
+
+	restoreMutex.Lock()
+	if targetMissingSince.IsZero() {
+		targetMissingSince = time.Now()
+	}
+	missingFor := time.Since(targetMissingSince)
+	network := previousNetwork
+	if !enabled || network == "" || missingFor < gracePeriod {
+		restoreMutex.Unlock()
+		return
+	}
+	// Only try once per switch, the user may have moved on
+	previousNetwork = ""
+	restoreMutex.Unlock()
+
+	if status.Connected && status.SSID == network {
+		return
+	}
+
+	slog.Info("Restoring previous network", "adapter", adapter, "network", network)
+	if err := wifi.Connect(adapter, network); err != nil {
+		slog.Warn("Could not restore previous network", "network", network, "error", err)
+		notifyPolicy.Notify(notify.EventRestored, "Restore Failed", fmt.Sprintf("Could not reconnect to %s", network))
+		return
+	}
+
+	notifyPolicy.Notify(notify.EventRestored, "Network Restored", fmt.Sprintf("%s is out of range, reconnected to %s", targetNetwork, network))
+}
+
+// sampleSignal records the signal in the history while connected, at most
+// once per signalSampleInterval
+func sampleSignal(statusText string, status *wifi.ConnectionStatus) {
+	stateMutex.Lock()
+	due := time.Since(lastSignalSample) >= signalSampleInterval
+	if due {
+		lastSignalSample = time.Now()
+	}
+	stateMutex.Unlock()
+	if !due || historyStore == nil {
+		return
+	}
+
+	entry := history.Entry{
+		Time:   time.Now(),
+		State:  history.StateConnected,
+		SSID:   status.SSID,
+		BSSID:  status.BSSID,
+		Signal: status.SignalPercent(),
+		Reason: statusText,
+		Sample: true,
+	}
+	if err := historyStore.Append(entry); err != nil {
+		slog.Warn("Could not record signal sample", "error", err)
 	}
 }
 
-func updateState(state ConnectionState, statusText string) {
+// recordHistory appends a state transition to the connection history
+func recordHistory(state, reason string, status *wifi.ConnectionStatus, userInitiated bool) {
+	if historyStore == nil {
+		return
+	}
+
+	entry := history.Entry{Time: time.Now(), State: state, Reason: reason, UserInitiated: userInitiated}
+	if status != nil && status.Connected {
+		entry.SSID = status.SSID
+		entry.BSSID = status.BSSID
+		entry.Signal = status.SignalPercent()
+	}
+	if err := historyStore.Append(entry); err != nil {
+		slog.Warn("Could not record history", "error", err)
+	}
+}
+
+// applyWebhookTargets points the webhook dispatcher at the targets in c
+func applyWebhookTargets(c *config.Config) {
+	if webhooks == nil {
+		return
+	}
+	targets := []webhook.Target{}
+	for _, target := range c.Webhooks {
+		targets = append(targets, webhook.Target{URL: target.URL, Secret: target.Secret})
+	}
+	webhooks.SetTargets(targets)
+}
+
+// sendWebhook posts a state transition to the webhook targets
+func sendWebhook(state, previous ConnectionState, reason string, status *wifi.ConnectionStatus) {
+	if webhooks == nil {
+		return
+	}
+
+	cfgMutex.RLock()
+	event := webhook.Event{
+		Time:          time.Now(),
+		Host:          hostname,
+		State:         state.String(),
+		PreviousState: previous.String(),
+		Reason:        reason,
+		SSID:          cfg.SelectedNetwork,
+		Adapter:       cfg.SelectedAdapter,
+	}
+	cfgMutex.RUnlock()
+
+	if status != nil && status.Connected {
+		event.Signal = status.SignalPercent()
+	}
+	event.Text = fmt.Sprintf("%s: %s (%s)", hostname, reason, event.State)
+	webhooks.Send(event)
+}
+
+// getState returns the current connection state and status text
+func getState() (ConnectionState, string) {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	return currentState, currentStatusText
+}
+
+// updateState sets the connection state. status is the adapter status the
+// state was derived from, or nil if it is unknown.
+func updateState(state ConnectionState, statusText string, status *wifi.ConnectionStatus) {
+	setState(state, statusText, status, false)
+}
+
+// updateStateOnRequest sets the connection state after a user action, so
+// the history does not count leaving the connected state as a drop
+func updateStateOnRequest(state ConnectionState, statusText string, status *wifi.ConnectionStatus) {
+	setState(state, statusText, status, true)
+}
+
+func setState(state ConnectionState, statusText string, status *wifi.ConnectionStatus, userInitiated bool) {
 	stateMutex.Lock()
 	previousState := currentState
+	previousText := currentStatusText
+	firstState := !stateRecorded
+	transition := state != previousState || !stateRecorded
+	reconnected := transition && state == StateConnected && everConnected
 	currentState = state
+	currentStatusText = statusText
+	stateRecorded = true
+	everConnected = everConnected || state == StateConnected
+	if transition {
+		lastSignalSample = time.Now()
+	}
+
+	// A drop through searching ends the connection for the hooks too, but
+	// leaving on request does not, so the next connect is not announced
+	// twice
+	connectedHook := state == StateConnected && !hookConnected
+	disconnectedHook := state != StateConnected && hookConnected && !userInitiated
+	if connectedHook || disconnectedHook {
+		hookConnected = connectedHook
+	}
 	stateMutex.Unlock()
 
+	metrics.SetState(state.String())
+	if state == StateConnected && status != nil {
+		metrics.SetSignal(status.SignalPercent())
+	} else if state != StateConnected {
+		metrics.SetSignal(0)
+	}
+	if reconnected {
+		metrics.IncReconnects()
+	}
+
+	if state != previousState || statusText != previousText {
+		slog.Info("Status changed", "state", state, "previous", previousState, "status", statusText)
+	}
+	if transition {
+		recordHistory(state.String(), statusText, status, userInitiated)
+		sendWebhook(state, previousState, statusText, status)
+	} else if state == StateConnected && status != nil {
+		sampleSignal(statusText, status)
+	}
+	publishStatus(status)
+
+	if !daemonMode {
+		updateTray(state, statusText)
+	}
+
+	switch {
+	case connectedHook:
+		runHook(hooks.EventConnected, previousState, status)
+	case disconnectedHook:
+		runHook(hooks.EventDisconnected, previousState, status)
+	}
+
+	// Leaving the connected state may be the start of a flap, also when
+	// the connection drops to searching
+	if previousState == StateConnected && state != StateConnected && !firstState && !userInitiated {
+		notifyPolicy.ConnectionLost()
+	}
+
+	cfgMutex.RLock()
+	targetNetwork := cfg.SelectedNetwork
+	threshold := cfg.LowSignalThreshold
+	cfgMutex.RUnlock()
+	if targetNetwork == "" {
+		return
+	}
+
+	// Show notifications on state changes, unless the user paused
+	// auto-connect
+	switch {
+	case previousState == StateConnected && state == StateDisconnected && !isPaused():
+		notifyPolicy.Notify(notify.EventDisconnected, "Disconnected", fmt.Sprintf("Lost connection to %s", targetNetwork))
+	case previousState != StateConnected && state == StateConnected && !firstState:
+		notifyPolicy.Notify(notify.EventConnected, "Connected", fmt.Sprintf("Successfully connected to %s", targetNetwork))
+	}
+
+	if state == StateConnected && status != nil {
+		checkSignal(targetNetwork, status.SignalPercent(), threshold)
+	}
+}
+
+// checkSignal warns once when the signal drops below threshold percent
+func checkSignal(network string, signal, threshold int) {
+	stateMutex.Lock()
+	low := signal < threshold
+	warn := low && !lowSignalWarned
+	lowSignalWarned = low
+	stateMutex.Unlock()
+
+	if warn {
+		notifyPolicy.Notify(notify.EventLowSignal, "Weak Signal", fmt.Sprintf("Signal to %s is down to %d%%", network, signal))
+	}
+}
+
+// runHook runs the hook command configured for event, if any. previous is
+// the state before the event.
+func runHook(event string, previous ConnectionState, status *wifi.ConnectionStatus) {
+	cfgMutex.RLock()
+	command := cfg.Hooks[event]
+	timeout := time.Duration(cfg.HookTimeout) * time.Second
+	info := hooks.Info{
+		Event:         event,
+		SSID:          cfg.SelectedNetwork,
+		Adapter:       cfg.SelectedAdapter,
+		Signal:        -1,
+		PreviousState: previous.String(),
+	}
+	cfgMutex.RUnlock()
+
+	if command == "" {
+		return
+	}
+	if status != nil && status.Connected {
+		info.Signal = status.SignalPercent()
+	}
+	slog.Info("Running hook", "event", event)
+	fireHook(command, info, timeout)
+}
+
+// updateTray shows the connection state in the tray icon and menu
+func updateTray(state ConnectionState, statusText string) {
 	// Update icon based on state
 	switch state {
 	case StateConnected:
@@ -280,28 +1252,74 @@ func updateState(state ConnectionState, statusText string) {
 	if mStatusItem != nil {
 		mStatusItem.SetTitle("Status: " + statusText)
 	}
+}
 
-	// Show notification on state change (connected -> disconnected)
-	if previousState == StateConnected && state == StateDisconnected {
-		cfgMutex.RLock()
-		targetNetwork := cfg.SelectedNetwork
-		cfgMutex.RUnlock()
-		if targetNetwork != "" {
-			showNotification("Disconnected", fmt.Sprintf("Lost connection to %s", targetNetwork))
-		}
+func showNotification(title, message string, actions ...notify.Action) {
+	notifierMutex.Lock()
+	if notifier == nil {
+		notifier, _ = notify.New(notify.KindAuto)
 	}
-}
+	n := notifier
+	notifierMutex.Unlock()
 
-func showNotification(title, message string) {
-	notification := toast.Notification{
-		AppID:   "Quadmax WiFi Connector",
-		Title:   title,
-		Message: message,
-		Audio:   toast.Default,
+	slog.Info("Notification", "title", title, "message", message, "notifier", n.Name())
+	if n.Name() == notify.KindLog {
+		return
 	}
 
 	// Best effort - don't block on notification errors
 	go func() {
-		_ = notification.Push()
+		if err := n.Notify(title, message, actions...); err != nil {
+			slog.Warn("Could not show notification", "notifier", n.Name(), "error", err)
+		}
 	}()
 }
+
+// showEventNotification shows a notification let through by the policy,
+// with the actions that help with event
+func showEventNotification(event notify.Event, title, message string) {
+	showNotification(title, message, notify.ActionsFor(event)...)
+}
+
+// handleNotificationAction runs the tray menu handler for a clicked
+// notification action
+func handleNotificationAction(id string) {
+	slog.Info("Notification action", "action", id)
+	switch id {
+	case notify.ActionRetry.ID:
+		go attemptConnection()
+	case notify.ActionSettings.ID:
+		openSettings()
+	case notify.ActionPause.ID:
+		pauseAutoConnect(time.Hour)
+	default:
+		slog.Warn("Unknown notification action", "action", id)
+	}
+}
+
+// setNotifier selects the notifier for kind, falling back to automatic
+// selection if it is unavailable
+func setNotifier(kind string) {
+	// Without a desktop session, notifications only go to the log
+	if daemonMode && (kind == notify.KindAuto || kind == "") {
+		kind = notify.KindLog
+	}
+
+	notifierMutex.Lock()
+	unchanged := notifier != nil && notifierKind == kind
+	notifierMutex.Unlock()
+	if unchanged {
+		return
+	}
+
+	n, err := notify.New(kind)
+	if err != nil {
+		slog.Warn("Notifier unavailable, selecting automatically", "notifier", kind, "error", err)
+		n, _ = notify.New(notify.KindAuto)
+	}
+
+	notifierMutex.Lock()
+	notifier = n
+	notifierKind = kind
+	notifierMutex.Unlock()
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection states reported by the state gauge
+var states = []string{"connected", "searching", "disconnected"}
+
+var (
+	connectionState = newVec("gauge", "quadmax_connection_state",
+		"Current connection state, 1 for the active state", "state")
+	signalPercent = newVec("gauge", "quadmax_signal_percent",
+		"Signal strength of the target network in percent")
+	paused = newVec("gauge", "quadmax_autoconnect_paused",
+		"1 while auto-connect is paused")
+	reconnects = newVec("counter", "quadmax_reconnects_total",
+		"Connections to the target network after it was lost")
+	errorsTotal = newVec("counter", "quadmax_errors_total",
+		"Errors by type", "type")
+	connectLatency = newHistogram("quadmax_connect_duration_seconds",
+		"Time from starting a connection attempt to a verified connection",
+		[]float64{1, 2, 3, 5, 10, 20, 30, 60})
+	netshDuration = newHistogram("quadmax_netsh_duration_seconds",
+		"Duration of netsh commands",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10}, "command")
+
+	all = []metric{connectionState, signalPercent, paused, reconnects, errorsTotal, connectLatency, netshDuration}
+)
+
+func init() {
+	// Report zero values before the first poll
+	paused.set(0)
+	reconnects.add(0)
+	signalPercent.set(0)
+}
+
+// SetState marks state as the active connection state
+func SetState(state string) {
+	for _, s := range states {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		connectionState.set(value, s)
+	}
+}
+
+// SetSignal records the signal strength in percent
+func SetSignal(percent int) {
+	signalPercent.set(float64(percent))
+}
+
+// SetPaused records whether auto-connect is paused
+func SetPaused(isPaused bool) {
+	value := 0.0
+	if isPaused {
+		value = 1
+	}
+	paused.set(value)
+}
+
+// IncReconnects counts a reconnection to the target network
+func IncReconnects() {
+	reconnects.add(1)
+}
+
+// IncError counts an error of the given type, such as "scan" or "connect"
+func IncError(errorType string) {
+	errorsTotal.add(1, errorType)
+}
+
+// ObserveConnect records how long a successful connection attempt took
+func ObserveConnect(d time.Duration) {
+	connectLatency.observe(d)
+}
+
+// ObserveNetsh records the duration of a netsh command and counts failures
+func ObserveNetsh(command string, d time.Duration, err error) {
+	netshDuration.observe(d, command)
+	if err != nil {
+		IncError("netsh_" + command)
+	}
+}
+
+// Handler serves all metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, m := range all {
+			m.write(w)
+		}
+	})
+}
+
+// Server serves the metrics endpoint
+type Server struct {
+	httpServer *http.Server
+}
+
+// Start serves /metrics on 127.0.0.1 at port
+func Start(port int) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	s := &Server{httpServer: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+	go s.httpServer.Serve(listener)
+	return s, nil
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape runs Handler and returns the exposition lines
+func scrape(t *testing.T) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+}
+
+// requireLines checks that every wanted line appears in lines
+func requireLines(t *testing.T, lines []string, want ...string) {
+	t.Helper()
+	have := map[string]bool{}
+	for _, line := range lines {
+		have[line] = true
+	}
+	for _, line := range want {
+		if !have[line] {
+			t.Errorf("missing line %q", line)
+		}
+	}
+}
+
+func TestHelpAndType(t *testing.T) {
+	lines := scrape(t)
+	requireLines(t, lines,
+		"# HELP quadmax_connection_state Current connection state, 1 for the active state",
+		"# TYPE quadmax_connection_state gauge",
+		"# TYPE quadmax_reconnects_total counter",
+		"# TYPE quadmax_connect_duration_seconds histogram",
+		"# TYPE quadmax_netsh_duration_seconds histogram",
+		// Zero values are reported before the first poll
+		"quadmax_autoconnect_paused 0",
+		"quadmax_reconnects_total 0",
+	)
+
+	// Each family has its HELP line directly before its TYPE line
+	for i, line := range lines {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name = strings.Fields(name)[0]
+			if i == 0 || !strings.HasPrefix(lines[i-1], "# HELP "+name+" ") {
+				t.Errorf("TYPE line for %s is not preceded by its HELP line", name)
+			}
+		}
+	}
+}
+
+func TestSetState(t *testing.T) {
+	for _, state := range []string{"searching", "connected", "disconnected"} {
+		SetState(state)
+
+		active := 0
+		for _, line := range scrape(t) {
+			if !strings.HasPrefix(line, "quadmax_connection_state{") {
+				continue
+			}
+			if strings.HasSuffix(line, " 1") {
+				active++
+				if line != `quadmax_connection_state{state="`+state+`"} 1` {
+					t.Errorf("after SetState(%q) active line is %q", state, line)
+				}
+			} else if !strings.HasSuffix(line, " 0") {
+				t.Errorf("unexpected state line %q", line)
+			}
+		}
+		if active != 1 {
+			t.Errorf("after SetState(%q) %d states are 1, want exactly 1", state, active)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	ObserveConnect(500 * time.Millisecond)
+	ObserveConnect(3 * time.Second)
+	ObserveConnect(90 * time.Second)
+
+	// Buckets are cumulative and +Inf counts every observation
+	requireLines(t, scrape(t),
+		`quadmax_connect_duration_seconds_bucket{le="1"} 1`,
+		`quadmax_connect_duration_seconds_bucket{le="2"} 1`,
+		`quadmax_connect_duration_seconds_bucket{le="3"} 2`,
+		`quadmax_connect_duration_seconds_bucket{le="5"} 2`,
+		`quadmax_connect_duration_seconds_bucket{le="60"} 2`,
+		`quadmax_connect_duration_seconds_bucket{le="+Inf"} 3`,
+		`quadmax_connect_duration_seconds_sum 93.5`,
+		`quadmax_connect_duration_seconds_count 3`,
+	)
+
+	ObserveNetsh("scan", 200*time.Millisecond, nil)
+	requireLines(t, scrape(t),
+		`quadmax_netsh_duration_seconds_bucket{command="scan",le="0.1"} 0`,
+		`quadmax_netsh_duration_seconds_bucket{command="scan",le="0.25"} 1`,
+		`quadmax_netsh_duration_seconds_bucket{command="scan",le="+Inf"} 1`,
+		`quadmax_netsh_duration_seconds_sum{command="scan"} 0.2`,
+		`quadmax_netsh_duration_seconds_count{command="scan"} 1`,
+	)
+}
+
+func TestLabelQuoting(t *testing.T) {
+	IncError(`say "hi"` + "\n" + `c:\netsh` + "\tWLAN é")
+	requireLines(t, scrape(t), `quadmax_errors_total{type="say \"hi\"\nc:\\netsh`+"\tWLAN é"+`"} 1`)
+}
@@ -0,0 +1,123 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// entity is a Home Assistant entity announced through MQTT discovery
+type entity struct {
+	component string
+	objectID  string
+	config    map[string]interface{}
+}
+
+// entities returns the Home Assistant entities for the client's topics
+func (c *Client) entities() []entity {
+	state := c.topic("state")
+	command := c.topic("command")
+
+	return []entity{
+		{"sensor", "state", map[string]interface{}{
+			"name":           "Connection",
+			"state_topic":    state,
+			"value_template": "{{ value_json.state }}",
+			"icon":           "mdi:wifi",
+		}},
+		{"sensor", "ssid", map[string]interface{}{
+			"name":           "SSID",
+			"state_topic":    state,
+			"value_template": "{{ value_json.ssid }}",
+			"icon":           "mdi:access-point-network",
+		}},
+		{"sensor", "signal", map[string]interface{}{
+			"name":                "Signal",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.signal }}",
+			"unit_of_measurement": "%",
+			"state_class":         "measurement",
+			"icon":                "mdi:wifi-strength-2",
+		}},
+		{"binary_sensor", "reachable", map[string]interface{}{
+			"name":           "Launch monitor",
+			"state_topic":    state,
+			"value_template": "{{ 'ON' if value_json.reachable else 'OFF' }}",
+			"device_class":   "connectivity",
+		}},
+		{"switch", "paused", map[string]interface{}{
+			"name":           "Pause auto-connect",
+			"state_topic":    state,
+			"value_template": "{{ 'ON' if value_json.paused else 'OFF' }}",
+			"command_topic":  command,
+			"payload_on":     CommandPause,
+			"payload_off":    CommandResume,
+			"state_on":       "ON",
+			"state_off":      "OFF",
+			"icon":           "mdi:pause",
+		}},
+		{"button", "connect", map[string]interface{}{
+			"name":          "Connect",
+			"command_topic": command,
+			"payload_press": CommandConnect,
+			"icon":          "mdi:wifi-arrow-up",
+		}},
+		{"button", "disconnect", map[string]interface{}{
+			"name":          "Disconnect",
+			"command_topic": command,
+			"payload_press": CommandDisconnect,
+			"icon":          "mdi:wifi-off",
+		}},
+	}
+}
+
+// publishDiscovery publishes retained Home Assistant discovery configs so
+// the entities appear without manual setup
+func (c *Client) publishDiscovery(client paho.Client) {
+	device := map[string]interface{}{
+		"identifiers": []string{"quadmax_wifi_" + c.opts.NodeID},
+		"name":        c.opts.DeviceName,
+		"model":       "Quadmax WiFi Connector",
+		"sw_version":  c.opts.Version,
+	}
+
+	for _, e := range c.entities() {
+		e.config["unique_id"] = "quadmax_wifi_" + c.opts.NodeID + "_" + e.objectID
+		e.config["object_id"] = "quadmax_wifi_" + c.opts.NodeID + "_" + e.objectID
+		e.config["availability_topic"] = c.topic("availability")
+		e.config["device"] = device
+
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			continue
+		}
+		topic := c.opts.DiscoveryPrefix + "/" + e.component + "/quadmax_wifi_" + c.opts.NodeID + "/" + e.objectID + "/config"
+		token := client.Publish(topic, 1, true, payload)
+		if !token.WaitTimeout(5 * time.Second) {
+			slog.Warn("Timed out publishing Home Assistant discovery", "topic", topic)
+		} else if token.Error() != nil {
+			slog.Warn("Could not publish Home Assistant discovery", "topic", topic, "error", token.Error())
+		}
+	}
+}
+
+// NodeID turns a host name into an ID usable in topics and entity IDs
+func NodeID(hostname string) string {
+	id := []rune{}
+	for _, r := range hostname {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			id = append(id, r)
+		case r >= 'A' && r <= 'Z':
+			id = append(id, r+'a'-'A')
+		default:
+			id = append(id, '_')
+		}
+	}
+	if len(id) == 0 {
+		return "default"
+	}
+	return string(id)
+}
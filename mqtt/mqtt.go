@@ -0,0 +1,149 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Commands accepted on the command topic
+const (
+	CommandConnect    = "connect"
+	CommandDisconnect = "disconnect"
+	CommandPause      = "pause"
+	CommandResume     = "resume"
+)
+
+// Options configures the MQTT client
+type Options struct {
+	Broker   string // e.g. tcp://homeassistant.local:1883
+	Username string
+	Password string
+
+	// Topic is the base topic, the client publishes to Topic/state and
+	// Topic/availability and listens on Topic/command
+	Topic string
+
+	// Discovery publishes Home Assistant discovery configs under
+	// DiscoveryPrefix, identifying this machine as NodeID
+	Discovery       bool
+	DiscoveryPrefix string
+	NodeID          string
+	DeviceName      string
+	Version         string
+}
+
+// State is the connection state published on the state topic
+type State struct {
+	State     string `json:"state"`
+	Message   string `json:"message"`
+	SSID      string `json:"ssid"`
+	Signal    int    `json:"signal"` // percent
+	Reachable bool   `json:"reachable"`
+	Paused    bool   `json:"paused"`
+}
+
+// Client publishes the connection state to an MQTT broker and passes
+// commands from it to a handler
+type Client struct {
+	opts    Options
+	handler func(command string)
+	client  paho.Client
+
+	mu   sync.Mutex
+	last []byte
+}
+
+// Start connects to the broker in the background, reconnecting as needed.
+// handler is called with each command received on the command topic.
+func Start(opts Options, handler func(command string)) (*Client, error) {
+	if opts.Broker == "" {
+		return nil, errors.New("no MQTT broker configured")
+	}
+	opts.Topic = strings.TrimSuffix(opts.Topic, "/")
+
+	c := &Client{opts: opts, handler: handler}
+
+	clientOpts := paho.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID("quadmax-wifi-"+opts.NodeID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetWill(c.topic("availability"), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(30 * time.Second).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("Lost connection to MQTT broker", "error", err)
+		})
+
+	c.client = paho.NewClient(clientOpts)
+	// With connect retry on, the token only completes once connected
+	c.client.Connect()
+	slog.Info("Started MQTT client", "broker", opts.Broker, "topic", opts.Topic)
+	return c, nil
+}
+
+// Close marks the device offline and disconnects
+func (c *Client) Close() {
+	if c.client.IsConnected() {
+		c.client.Publish(c.topic("availability"), 1, true, "offline").WaitTimeout(time.Second)
+	}
+	c.client.Disconnect(250)
+}
+
+// Publish sends the state if it changed since it was last sent
+func (c *Client) Publish(s State) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	changed := string(payload) != string(c.last)
+	c.last = payload
+	c.mu.Unlock()
+
+	if changed && c.client.IsConnected() {
+		c.client.Publish(c.topic("state"), 1, true, payload)
+	}
+}
+
+// onConnect announces the device and resends the state after every
+// (re)connect
+func (c *Client) onConnect(client paho.Client) {
+	slog.Info("Connected to MQTT broker", "broker", c.opts.Broker)
+
+	client.Subscribe(c.topic("command"), 1, func(_ paho.Client, msg paho.Message) {
+		command := strings.ToLower(strings.TrimSpace(string(msg.Payload())))
+		slog.Info("MQTT command", "command", command)
+		switch command {
+		case CommandConnect, CommandDisconnect, CommandPause, CommandResume:
+			c.handler(command)
+		default:
+			slog.Warn("Unknown MQTT command", "command", command)
+		}
+	})
+
+	if c.opts.Discovery {
+		c.publishDiscovery(client)
+	}
+	client.Publish(c.topic("availability"), 1, true, "online")
+
+	c.mu.Lock()
+	last := c.last
+	c.mu.Unlock()
+	if last != nil {
+		client.Publish(c.topic("state"), 1, true, last)
+	}
+}
+
+func (c *Client) topic(name string) string {
+	return c.opts.Topic + "/" + name
+}
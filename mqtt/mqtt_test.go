@@ -0,0 +1,223 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// broker is an embedded MQTT broker recording every message published
+type broker struct {
+	*server.Server
+	url string
+
+	mu       sync.Mutex
+	messages map[string][]string
+}
+
+func startBroker(t *testing.T) *broker {
+	t.Helper()
+	s := server.New(&server.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := s.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := s.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &broker{Server: s, url: "tcp://" + tcp.Address(), messages: map[string][]string{}}
+	err := s.Subscribe("#", 1, func(_ *server.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		b.messages[pk.TopicName] = append(b.messages[pk.TopicName], string(pk.Payload))
+		b.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return b
+}
+
+// published returns the payloads published on topic so far
+func (b *broker) published(topic string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.messages[topic]...)
+}
+
+// waitFor waits for the nth payload on topic and returns it
+func (b *broker) waitFor(t *testing.T, topic string, n int) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if payloads := b.published(topic); len(payloads) >= n {
+			return payloads[n-1]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("nothing published on %s", topic)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testOptions(b *broker) Options {
+	return Options{
+		Broker:          b.url,
+		Topic:           "quadmax/testhost/",
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+		NodeID:          "testhost",
+		DeviceName:      "Quadmax WiFi (testhost)",
+		Version:         "1.2.3",
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	b := startBroker(t)
+	c, err := Start(testOptions(b), func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	b.waitFor(t, "quadmax/testhost/availability", 1)
+	for _, e := range c.entities() {
+		topic := "homeassistant/" + e.component + "/quadmax_wifi_testhost/" + e.objectID + "/config"
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(b.waitFor(t, topic, 1)), &config); err != nil {
+			t.Fatalf("%s: %v", topic, err)
+		}
+		if config["unique_id"] != "quadmax_wifi_testhost_"+e.objectID {
+			t.Errorf("%s: unique_id %v", topic, config["unique_id"])
+		}
+		if config["availability_topic"] != "quadmax/testhost/availability" {
+			t.Errorf("%s: availability_topic %v", topic, config["availability_topic"])
+		}
+		device, _ := config["device"].(map[string]interface{})
+		if device["name"] != "Quadmax WiFi (testhost)" || device["sw_version"] != "1.2.3" {
+			t.Errorf("%s: device %v", topic, device)
+		}
+	}
+
+	var signal map[string]interface{}
+	json.Unmarshal([]byte(b.waitFor(t, "homeassistant/sensor/quadmax_wifi_testhost/signal/config", 1)), &signal)
+	if signal["state_topic"] != "quadmax/testhost/state" || signal["unit_of_measurement"] != "%" {
+		t.Errorf("signal sensor config %v", signal)
+	}
+	var paused map[string]interface{}
+	json.Unmarshal([]byte(b.waitFor(t, "homeassistant/switch/quadmax_wifi_testhost/paused/config", 1)), &paused)
+	if paused["command_topic"] != "quadmax/testhost/command" || paused["payload_on"] != CommandPause {
+		t.Errorf("pause switch config %v", paused)
+	}
+}
+
+func TestPublishState(t *testing.T) {
+	b := startBroker(t)
+	opts := testOptions(b)
+	opts.Discovery = false
+	c, err := Start(opts, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.waitFor(t, "quadmax/testhost/availability", 1); got != "online" {
+		t.Errorf("availability %q, want online", got)
+	}
+
+	connected := State{State: "connected", Message: "Connected to Quadmax", SSID: "Quadmax", Signal: 85, Reachable: true}
+	c.Publish(connected)
+	c.Publish(connected) // unchanged, not sent again
+	c.Publish(State{State: "disconnected", Message: "Quadmax not in range"})
+
+	var first State
+	if err := json.Unmarshal([]byte(b.waitFor(t, "quadmax/testhost/state", 1)), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first != connected {
+		t.Errorf("published %+v, want %+v", first, connected)
+	}
+	if second := b.waitFor(t, "quadmax/testhost/state", 2); !strings.Contains(second, `"state":"disconnected"`) {
+		t.Errorf("second state %s", second)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(b.published("quadmax/testhost/state")); n != 2 {
+		t.Errorf("published the state %d times, want 2", n)
+	}
+	if len(b.published("homeassistant/sensor/quadmax_wifi_testhost/state/config")) != 0 {
+		t.Error("published discovery with discovery off")
+	}
+
+	c.Close()
+	if got := b.waitFor(t, "quadmax/testhost/availability", 2); got != "offline" {
+		t.Errorf("availability after Close %q, want offline", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	b := startBroker(t)
+	commands := make(chan string, 10)
+	c, err := Start(testOptions(b), func(command string) { commands <- command })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The client subscribes before it announces itself online
+	b.waitFor(t, "quadmax/testhost/availability", 1)
+	for _, payload := range []string{"connect", " Disconnect\n", "explode", "PAUSE", "resume"} {
+		if err := b.Publish("quadmax/testhost/command", []byte(payload), false, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{CommandConnect, CommandDisconnect, CommandPause, CommandResume} {
+		select {
+		case got := <-commands:
+			if got != want {
+				t.Errorf("handler got %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handler never got %q", want)
+		}
+	}
+	select {
+	case got := <-commands:
+		t.Errorf("unexpected command %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNodeID(t *testing.T) {
+	tests := map[string]string{
+		"DESKTOP-AB12": "desktop_ab12",
+		"golf.local":   "golf_local",
+		"":             "default",
+	}
+	for in, want := range tests {
+		if got := NodeID(in); got != want {
+			t.Errorf("NodeID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStartWithoutBroker(t *testing.T) {
+	if _, err := Start(Options{}, nil); err == nil {
+		t.Error("Start without a broker succeeded")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/whenry/quadmax-wifi-connector/mqtt"
+)
+
+var mqttClient *mqtt.Client
+
+// startMQTT connects to the MQTT broker if it is enabled in the config
+func startMQTT() (*mqtt.Client, error) {
+	cfgMutex.RLock()
+	enabled := cfg.MQTTEnabled
+	nodeID := mqtt.NodeID(hostname)
+	opts := mqtt.Options{
+		Broker:          cfg.MQTTBroker,
+		Username:        cfg.MQTTUsername,
+		Password:        cfg.MQTTPassword,
+		Topic:           cfg.MQTTTopic,
+		Discovery:       cfg.MQTTDiscovery,
+		DiscoveryPrefix: cfg.MQTTDiscoveryPrefix,
+		NodeID:          nodeID,
+		DeviceName:      "Quadmax WiFi (" + hostname + ")",
+		Version:         version,
+	}
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		return nil, nil
+	}
+	if opts.Topic == "" {
+		opts.Topic = "quadmax/" + nodeID
+	}
+	return mqtt.Start(opts, handleMQTTCommand)
+}
+
+// handleMQTTCommand runs the tray menu handler for a command from the
+// broker
+func handleMQTTCommand(command string) {
+	switch command {
+	case mqtt.CommandConnect:
+		go attemptConnection()
+	case mqtt.CommandDisconnect:
+		go disconnectNow()
+	case mqtt.CommandPause:
+		pauseAutoConnect(0)
+	case mqtt.CommandResume:
+		resumeAutoConnect()
+	}
+}
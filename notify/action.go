@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"strings"
+	"sync"
+)
+
+// Action is a button on a notification
+type Action struct {
+	ID    string
+	Label string
+}
+
+// Actions offered on notifications
+var (
+	ActionRetry    = Action{ID: "retry", Label: "Retry now"}
+	ActionSettings = Action{ID: "settings", Label: "Open settings"}
+	ActionPause    = Action{ID: "pause", Label: "Pause 1h"}
+)
+
+// ActionsFor returns the actions that help with event
+func ActionsFor(event Event) []Action {
+	switch event {
+	case EventFailed, EventDisconnected:
+		return []Action{ActionRetry, ActionSettings, ActionPause}
+	case EventAdapterMissing:
+		return []Action{ActionSettings}
+	}
+	return nil
+}
+
+// ActionScheme is the URI scheme that activates notification actions on
+// Windows, where toast buttons can only launch URIs
+const ActionScheme = "quadmax-wifi"
+
+var (
+	actionHandler      func(id string)
+	actionHandlerMutex sync.Mutex
+)
+
+// SetActionHandler sets the function called with the ID of a clicked
+// notification action
+func SetActionHandler(handler func(id string)) {
+	actionHandlerMutex.Lock()
+	actionHandler = handler
+	actionHandlerMutex.Unlock()
+}
+
+func handleAction(id string) {
+	actionHandlerMutex.Lock()
+	handler := actionHandler
+	actionHandlerMutex.Unlock()
+
+	if handler != nil {
+		handler(id)
+	}
+}
+
+// ActionURI returns the URI that activates the action with the given ID
+func ActionURI(id string) string {
+	return ActionScheme + ":" + id
+}
+
+// ParseActionURI returns the action ID of a URI made by ActionURI
+func ParseActionURI(uri string) (string, bool) {
+	id, ok := strings.CutPrefix(uri, ActionScheme+":")
+	id = strings.Trim(id, "/")
+	return id, ok && id != ""
+}
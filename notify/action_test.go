@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestActionsFor(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  []Action
+	}{
+		{EventFailed, []Action{ActionRetry, ActionSettings, ActionPause}},
+		{EventDisconnected, []Action{ActionRetry, ActionSettings, ActionPause}},
+		{EventAdapterMissing, []Action{ActionSettings}},
+		{EventConnected, nil},
+		{EventLowSignal, nil},
+	}
+	for _, tt := range tests {
+		if got := ActionsFor(tt.event); !slices.Equal(got, tt.want) {
+			t.Errorf("ActionsFor(%s) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestActionURI(t *testing.T) {
+	for _, action := range []Action{ActionRetry, ActionSettings, ActionPause} {
+		id, ok := ParseActionURI(ActionURI(action.ID))
+		if !ok || id != action.ID {
+			t.Errorf("ParseActionURI(ActionURI(%q)) = %q, %v", action.ID, id, ok)
+		}
+	}
+	for _, uri := range []string{"quadmax-wifi:", "quadmax-wifi://", "https://example.com"} {
+		if id, ok := ParseActionURI(uri); ok {
+			t.Errorf("ParseActionURI(%q) = %q, want no action", uri, id)
+		}
+	}
+	if id, _ := ParseActionURI("quadmax-wifi://retry/"); id != "retry" {
+		t.Errorf("ParseActionURI with slashes = %q, want retry", id)
+	}
+}
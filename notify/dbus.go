@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusService   = "org.freedesktop.Notifications"
+	dbusPath      = "/org/freedesktop/Notifications"
+	dbusInterface = "org.freedesktop.Notifications"
+)
+
+var (
+	// The session bus connection is shared, so only listen once
+	dbusListenOnce sync.Once
+
+	// dbusSent holds the IDs of shown notifications that have actions
+	dbusSent      = map[uint32]bool{}
+	dbusSentMutex sync.Mutex
+)
+
+// dbusNotifier shows notifications through the freedesktop notification
+// service on the session bus
+type dbusNotifier struct {
+	conn *dbus.Conn
+}
+
+func newDBus() (Notifier, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var running bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, dbusService).Store(&running)
+	if err != nil {
+		return nil, err
+	}
+	if !running {
+		return nil, errors.New("no notification service on the session bus")
+	}
+
+	n := &dbusNotifier{conn: conn}
+	dbusListenOnce.Do(func() {
+		if err := n.listenForActions(); err != nil {
+			slog.Warn("Could not listen for notification actions", "error", err)
+		}
+	})
+	return n, nil
+}
+
+func (n *dbusNotifier) Notify(title, message string, actions ...Action) error {
+	// Actions are a flat list of key and label pairs
+	keys := []string{}
+	for _, action := range actions {
+		keys = append(keys, action.ID, action.Label)
+	}
+
+	var id uint32
+	obj := n.conn.Object(dbusService, dbusPath)
+	err := obj.Call(dbusInterface+".Notify", 0,
+		AppName,                   // app_name
+		uint32(0),                 // replaces_id
+		"network-wireless",        // app_icon
+		title,                     // summary
+		message,                   // body
+		keys,                      // actions
+		map[string]dbus.Variant{}, // hints
+		int32(-1),                 // expire_timeout, server default
+	).Store(&id)
+	if err != nil {
+		return err
+	}
+
+	if len(actions) > 0 {
+		dbusSentMutex.Lock()
+		dbusSent[id] = true
+		dbusSentMutex.Unlock()
+	}
+	return nil
+}
+
+// listenForActions passes clicked actions of our notifications to the
+// action handler
+func (n *dbusNotifier) listenForActions() error {
+	err := n.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(dbusPath),
+		dbus.WithMatchInterface(dbusInterface),
+	)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	n.conn.Signal(signals)
+	go func() {
+		for signal := range signals {
+			if len(signal.Body) < 1 {
+				continue
+			}
+			id, _ := signal.Body[0].(uint32)
+
+			dbusSentMutex.Lock()
+			ours := dbusSent[id]
+			switch signal.Name {
+			case dbusInterface + ".NotificationClosed":
+				delete(dbusSent, id)
+			case dbusInterface + ".ActionInvoked":
+				delete(dbusSent, id)
+			}
+			dbusSentMutex.Unlock()
+
+			if !ours || signal.Name != dbusInterface+".ActionInvoked" || len(signal.Body) < 2 {
+				continue
+			}
+			if key, ok := signal.Body[1].(string); ok {
+				handleAction(key)
+			}
+		}
+	}()
+	return nil
+}
+
+func (n *dbusNotifier) Name() string {
+	return KindDBus
+}
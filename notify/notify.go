@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+)
+
+// AppName is shown as the source of notifications
+const AppName = "Quadmax WiFi Connector"
+
+// Notifier kinds accepted by New
+const (
+	KindAuto  = "auto"
+	KindToast = "toast"
+	KindDBus  = "dbus"
+	KindLog   = "log"
+)
+
+// Notifier shows desktop notifications. Notifiers that cannot show action
+// buttons ignore actions.
+type Notifier interface {
+	Notify(title, message string, actions ...Action) error
+	Name() string
+}
+
+// New returns the notifier of the given kind. KindAuto picks Windows toasts
+// on Windows, desktop notifications over D-Bus where a notification server
+// is running, and the log otherwise.
+func New(kind string) (Notifier, error) {
+	switch kind {
+	case KindToast:
+		return newToast()
+	case KindDBus:
+		return newDBus()
+	case KindLog:
+		return logNotifier{}, nil
+	case KindAuto, "":
+		return auto(), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", kind)
+	}
+}
+
+func auto() Notifier {
+	if runtime.GOOS == "windows" {
+		if n, err := newToast(); err == nil {
+			return n
+		}
+	}
+	if n, err := newDBus(); err == nil {
+		return n
+	}
+	return logNotifier{}
+}
+
+// logNotifier writes notifications to the log when no desktop notification
+// service is available
+type logNotifier struct{}
+
+func (logNotifier) Notify(title, message string, actions ...Action) error {
+	slog.Info("Notification", "title", title, "message", message)
+	return nil
+}
+
+func (logNotifier) Name() string {
+	return KindLog
+}
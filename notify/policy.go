@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event identifies what a notification is about so the policy can filter it
+type Event string
+
+// Events the policy knows about
+const (
+	EventConnected      Event = "connected"
+	EventDisconnected   Event = "disconnected"
+	EventFailed         Event = "failed"
+	EventLowSignal      Event = "low_signal"
+	EventAdapterMissing Event = "adapter_missing"
+	EventSameNetwork    Event = "same_network"
+	EventRestored       Event = "restored"
+)
+
+// PolicyOptions configures a Policy
+type PolicyOptions struct {
+	// Enabled lists the events that are shown, missing events are dropped
+	Enabled map[Event]bool
+
+	// DedupWindow drops a notification identical to one shown within the
+	// window
+	DedupWindow time.Duration
+
+	// FlapWindow holds back disconnect notifications. If the connection
+	// comes back within the window, neither notification is shown. A
+	// connection coming back within the window after ConnectionLost is not
+	// announced either.
+	FlapWindow time.Duration
+
+	// QuietHours drops all notifications between QuietStart and QuietEnd,
+	// given as offsets from midnight. The range may wrap past midnight.
+	QuietHours bool
+	QuietStart time.Duration
+	QuietEnd   time.Duration
+}
+
+// Policy decides which notifications are passed on to be shown
+type Policy struct {
+	send func(event Event, title, message string)
+	now  func() time.Time
+
+	mu      sync.Mutex
+	opts    PolicyOptions
+	sent    map[string]time.Time
+	pending *time.Timer
+	lostAt  time.Time // when the connection last ended, zero once it is back
+}
+
+// NewPolicy returns a policy that passes allowed notifications to send
+func NewPolicy(opts PolicyOptions, send func(event Event, title, message string)) *Policy {
+	return &Policy{
+		send: send,
+		now:  time.Now,
+		opts: opts,
+		sent: map[string]time.Time{},
+	}
+}
+
+// SetOptions replaces the policy options
+func (p *Policy) SetOptions(opts PolicyOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.opts = opts
+}
+
+// ConnectionLost marks the end of a connection, whether or not a disconnect
+// notification is sent for it, so a quick reconnect counts as a flap
+func (p *Policy) ConnectionLost() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lostAt = p.now()
+}
+
+// Notify shows the notification for event if the policy allows it
+func (p *Policy) Notify(event Event, title, message string) {
+	p.mu.Lock()
+
+	// A reconnect within the flap window cancels the pending disconnect
+	// notification and is not worth announcing either. Disconnects are
+	// only held back while they are enabled.
+	if event == EventConnected {
+		flap := p.pending != nil ||
+			(p.opts.FlapWindow > 0 && !p.lostAt.IsZero() && p.now().Sub(p.lostAt) < p.opts.FlapWindow)
+		if p.pending != nil {
+			p.pending.Stop()
+			p.pending = nil
+		}
+		p.lostAt = time.Time{}
+		if flap {
+			p.mu.Unlock()
+			slog.Debug("Suppressed notification for connection flap", "title", title)
+			return
+		}
+	}
+
+	if event == EventDisconnected && p.opts.FlapWindow > 0 && p.opts.Enabled[EventDisconnected] {
+		if p.pending != nil {
+			p.pending.Stop()
+		}
+		var timer *time.Timer
+		timer = time.AfterFunc(p.opts.FlapWindow, func() {
+			p.mu.Lock()
+			if p.pending != timer {
+				p.mu.Unlock()
+				return
+			}
+			p.pending = nil
+			p.mu.Unlock()
+			p.deliver(event, title, message)
+		})
+		p.pending = timer
+		p.mu.Unlock()
+		return
+	}
+
+	p.mu.Unlock()
+	p.deliver(event, title, message)
+}
+
+// deliver applies the event, quiet hours and dedup rules and sends the
+// notification if it passes
+func (p *Policy) deliver(event Event, title, message string) {
+	p.mu.Lock()
+	now := p.now()
+
+	if !p.opts.Enabled[event] {
+		p.mu.Unlock()
+		slog.Debug("Suppressed disabled notification", "event", event, "title", title)
+		return
+	}
+	if p.inQuietHours(now) {
+		p.mu.Unlock()
+		slog.Debug("Suppressed notification during quiet hours", "event", event, "title", title)
+		return
+	}
+
+	key := string(event) + "\x00" + title + "\x00" + message
+	if last, ok := p.sent[key]; ok && now.Sub(last) < p.opts.DedupWindow {
+		p.mu.Unlock()
+		slog.Debug("Suppressed duplicate notification", "event", event, "title", title)
+		return
+	}
+	p.sent[key] = now
+	for k, t := range p.sent {
+		if now.Sub(t) >= p.opts.DedupWindow {
+			delete(p.sent, k)
+		}
+	}
+	p.mu.Unlock()
+
+	p.send(event, title, message)
+}
+
+func (p *Policy) inQuietHours(now time.Time) bool {
+	if !p.opts.QuietHours || p.opts.QuietStart == p.opts.QuietEnd {
+		return false
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+	if p.opts.QuietStart < p.opts.QuietEnd {
+		return offset >= p.opts.QuietStart && offset < p.opts.QuietEnd
+	}
+	return offset >= p.opts.QuietStart || offset < p.opts.QuietEnd
+}
+
+// ParseClock parses a time of day in 24 hour HH:MM format as an offset from
+// midnight
+func ParseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPolicy returns a policy with all events enabled, a clock set by the
+// returned function and a function listing the titles sent so far
+func testPolicy(opts PolicyOptions) (*Policy, func(time.Time), func() []string) {
+	if opts.Enabled == nil {
+		opts.Enabled = map[Event]bool{}
+		for _, event := range []Event{EventConnected, EventDisconnected, EventFailed, EventLowSignal, EventAdapterMissing, EventSameNetwork, EventRestored} {
+			opts.Enabled[event] = true
+		}
+	}
+
+	var mu sync.Mutex
+	var titles []string
+	p := NewPolicy(opts, func(event Event, title, message string) {
+		mu.Lock()
+		titles = append(titles, title)
+		mu.Unlock()
+	})
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	p.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	setNow := func(t time.Time) {
+		mu.Lock()
+		now = t
+		mu.Unlock()
+	}
+	sent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), titles...)
+	}
+	return p, setNow, sent
+}
+
+func clock(hour, minute int) time.Time {
+	return time.Date(2026, 3, 10, hour, minute, 0, 0, time.Local)
+}
+
+func TestPolicyDisabledEvents(t *testing.T) {
+	p, _, sent := testPolicy(PolicyOptions{Enabled: map[Event]bool{EventFailed: true}})
+	p.Notify(EventConnected, "Connected", "")
+	p.Notify(EventFailed, "Connection Failed", "")
+	p.Notify(EventLowSignal, "Low Signal", "")
+
+	if got := sent(); len(got) != 1 || got[0] != "Connection Failed" {
+		t.Errorf("sent %v, want only the enabled event", got)
+	}
+}
+
+func TestPolicyQuietHours(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		at         time.Time
+		quiet      bool
+	}{
+		{"before same-day range", 9 * time.Hour, 17 * time.Hour, clock(8, 59), false},
+		{"at same-day start", 9 * time.Hour, 17 * time.Hour, clock(9, 0), true},
+		{"inside same-day range", 9 * time.Hour, 17 * time.Hour, clock(12, 0), true},
+		{"at same-day end", 9 * time.Hour, 17 * time.Hour, clock(17, 0), false},
+		{"before wrapping range", 22 * time.Hour, 7 * time.Hour, clock(21, 59), false},
+		{"at wrapping start", 22 * time.Hour, 7 * time.Hour, clock(22, 0), true},
+		{"before midnight", 22 * time.Hour, 7 * time.Hour, clock(23, 30), true},
+		{"after midnight", 22 * time.Hour, 7 * time.Hour, clock(3, 0), true},
+		{"at wrapping end", 22 * time.Hour, 7 * time.Hour, clock(7, 0), false},
+		{"midday outside wrapping range", 22 * time.Hour, 7 * time.Hour, clock(12, 0), false},
+		{"empty range", 8 * time.Hour, 8 * time.Hour, clock(8, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, setNow, sent := testPolicy(PolicyOptions{QuietHours: true, QuietStart: tt.start, QuietEnd: tt.end})
+			setNow(tt.at)
+			p.Notify(EventFailed, "Connection Failed", "")
+			if quiet := len(sent()) == 0; quiet != tt.quiet {
+				t.Errorf("quiet = %v, want %v", quiet, tt.quiet)
+			}
+		})
+	}
+}
+
+func TestPolicyDedup(t *testing.T) {
+	p, setNow, sent := testPolicy(PolicyOptions{DedupWindow: time.Minute})
+
+	setNow(clock(12, 0))
+	p.Notify(EventFailed, "Connection Failed", "Could not connect to Quadmax")
+	setNow(clock(12, 0).Add(30 * time.Second))
+	p.Notify(EventFailed, "Connection Failed", "Could not connect to Quadmax") // duplicate
+	p.Notify(EventFailed, "Connection Failed", "Could not connect to Other")   // different message
+	setNow(clock(12, 1))
+	p.Notify(EventFailed, "Connection Failed", "Could not connect to Quadmax") // window passed
+
+	if got := sent(); len(got) != 3 {
+		t.Errorf("sent %d notifications, want 3: %v", len(got), got)
+	}
+}
+
+func TestPolicyFlap(t *testing.T) {
+	const window = 50 * time.Millisecond
+
+	t.Run("reconnect within window", func(t *testing.T) {
+		p, _, sent := testPolicy(PolicyOptions{FlapWindow: window})
+		p.Notify(EventDisconnected, "Disconnected", "")
+		p.Notify(EventConnected, "Connected", "")
+		time.Sleep(2 * window)
+		if got := sent(); len(got) != 0 {
+			t.Errorf("sent %v for a flap, want nothing", got)
+		}
+	})
+
+	t.Run("disconnect outlasts window", func(t *testing.T) {
+		p, _, sent := testPolicy(PolicyOptions{FlapWindow: window})
+		p.Notify(EventDisconnected, "Disconnected", "")
+		if got := sent(); len(got) != 0 {
+			t.Fatalf("disconnect sent before the window passed: %v", got)
+		}
+		time.Sleep(2 * window)
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 2 || got[0] != "Disconnected" || got[1] != "Connected" {
+			t.Errorf("sent %v, want Disconnected then Connected", got)
+		}
+	})
+
+	t.Run("disconnects disabled", func(t *testing.T) {
+		p, _, sent := testPolicy(PolicyOptions{
+			Enabled:    map[Event]bool{EventConnected: true},
+			FlapWindow: window,
+		})
+		p.Notify(EventDisconnected, "Disconnected", "")
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 1 || got[0] != "Connected" {
+			t.Errorf("sent %v, want Connected", got)
+		}
+	})
+
+	// Connected, searching, connected again: no disconnect notification is
+	// sent, only ConnectionLost marks the drop
+	t.Run("reconnect through searching", func(t *testing.T) {
+		p, setNow, sent := testPolicy(PolicyOptions{FlapWindow: time.Minute})
+		setNow(clock(12, 0))
+		p.ConnectionLost()
+		setNow(clock(12, 0).Add(30 * time.Second))
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 0 {
+			t.Fatalf("sent %v for a flap through searching, want nothing", got)
+		}
+
+		// A reconnect after the window is announced
+		setNow(clock(12, 5))
+		p.ConnectionLost()
+		setNow(clock(12, 7))
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 1 || got[0] != "Connected" {
+			t.Errorf("sent %v, want Connected after the window", got)
+		}
+
+		// A connected notification without a lost connection is sent
+		setNow(clock(12, 30))
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 2 {
+			t.Errorf("sent %v, want a second Connected", got)
+		}
+	})
+
+	t.Run("lost with disconnects disabled", func(t *testing.T) {
+		p, _, sent := testPolicy(PolicyOptions{
+			Enabled:    map[Event]bool{EventConnected: true},
+			FlapWindow: time.Minute,
+		})
+		p.ConnectionLost()
+		p.Notify(EventDisconnected, "Disconnected", "")
+		p.Notify(EventConnected, "Connected", "")
+		if got := sent(); len(got) != 0 {
+			t.Errorf("sent %v for a flap, want nothing", got)
+		}
+	})
+}
+
+func TestParseClock(t *testing.T) {
+	if d, err := ParseClock("07:30"); err != nil || d != 7*time.Hour+30*time.Minute {
+		t.Errorf("ParseClock(07:30) = %v, %v", d, err)
+	}
+	for _, s := range []string{"", "7", "25:00", "07:60"} {
+		if _, err := ParseClock(s); err == nil {
+			t.Errorf("ParseClock(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package notify
+
+import "sync"
+
+// Notification is a notification captured by a Recorder
+type Notification struct {
+	Title   string
+	Message string
+	Actions []Action
+}
+
+// Recorder is a Notifier that keeps the notifications it is given, for
+// tests
+type Recorder struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (r *Recorder) Notify(title, message string, actions ...Action) error {
+	r.mu.Lock()
+	r.notifications = append(r.notifications, Notification{Title: title, Message: message, Actions: actions})
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Recorder) Name() string {
+	return "recorder"
+}
+
+// Notifications returns the notifications recorded so far
+func (r *Recorder) Notifications() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Notification(nil), r.notifications...)
+}
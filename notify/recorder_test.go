@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRecorderThroughPolicy(t *testing.T) {
+	var rec Recorder
+	var n Notifier = &rec
+
+	// Send the way the app does, with the actions for each event
+	p := NewPolicy(PolicyOptions{Enabled: map[Event]bool{
+		EventConnected:      true,
+		EventFailed:         true,
+		EventAdapterMissing: true,
+	}}, func(event Event, title, message string) {
+		n.Notify(title, message, ActionsFor(event)...)
+	})
+
+	p.Notify(EventFailed, "Connection Failed", "Could not connect to Quadmax")
+	p.Notify(EventLowSignal, "Low Signal", "Quadmax is at 20%") // disabled
+	p.Notify(EventAdapterMissing, "Adapter Missing", "The WiFi adapter Wi-Fi 2 was not found")
+	p.Notify(EventConnected, "Connected", "Successfully connected to Quadmax")
+
+	want := []Notification{
+		{Title: "Connection Failed", Message: "Could not connect to Quadmax", Actions: []Action{ActionRetry, ActionSettings, ActionPause}},
+		{Title: "Adapter Missing", Message: "The WiFi adapter Wi-Fi 2 was not found", Actions: []Action{ActionSettings}},
+		{Title: "Connected", Message: "Successfully connected to Quadmax"},
+	}
+	got := rec.Notifications()
+	if len(got) != len(want) {
+		t.Fatalf("recorded %d notifications, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Title != want[i].Title || got[i].Message != want[i].Message || !slices.Equal(got[i].Actions, want[i].Actions) {
+			t.Errorf("notification %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// The returned slice is a copy
+	got[0].Title = "changed"
+	if rec.Notifications()[0].Title != "Connection Failed" {
+		t.Error("Notifications returned the recorder's own slice")
+	}
+	if n.Name() != "recorder" {
+		t.Errorf("Name = %q", n.Name())
+	}
+}
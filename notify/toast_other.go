@@ -0,0 +1,9 @@
+//go:build !windows
+
+package notify
+
+import "errors"
+
+func newToast() (Notifier, error) {
+	return nil, errors.New("toast notifications are only available on Windows")
+}
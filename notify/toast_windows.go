@@ -0,0 +1,78 @@
+//go:build windows
+
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/go-toast/toast"
+	"golang.org/x/sys/windows/registry"
+)
+
+// toastNotifier shows Windows toast notifications
+type toastNotifier struct{}
+
+var registerOnce sync.Once
+
+func newToast() (Notifier, error) {
+	registerOnce.Do(func() {
+		if err := registerActionScheme(); err != nil {
+			slog.Warn("Could not register notification actions", "error", err)
+		}
+	})
+	return toastNotifier{}, nil
+}
+
+func (toastNotifier) Notify(title, message string, actions ...Action) error {
+	notification := toast.Notification{
+		AppID:   AppName,
+		Title:   title,
+		Message: message,
+		Audio:   toast.Default,
+	}
+	for _, action := range actions {
+		notification.Actions = append(notification.Actions, toast.Action{
+			Type:      "protocol",
+			Label:     action.Label,
+			Arguments: ActionURI(action.ID),
+		})
+	}
+	return notification.Push()
+}
+
+func (toastNotifier) Name() string {
+	return KindToast
+}
+
+// registerActionScheme registers ActionScheme for the current user so that
+// toast buttons launch this executable with -action. A running instance
+// gets the action forwarded from the new launch.
+func registerActionScheme() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	root := `Software\Classes\` + ActionScheme
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, root, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.SetStringValue("", "URL:"+AppName); err != nil {
+		return err
+	}
+	if err := key.SetStringValue("URL Protocol", ""); err != nil {
+		return err
+	}
+
+	command, _, err := registry.CreateKey(registry.CURRENT_USER, root+`\shell\open\command`, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer command.Close()
+	return command.SetStringValue("", fmt.Sprintf(`"%s" -action "%%1"`, exe))
+}
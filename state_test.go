@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/hooks"
+)
+
+// recordHooks starts each test from a fresh state with hooks configured
+// for every event and returns a function listing the hooks fired so far
+func recordHooks(t *testing.T) func() []hooks.Info {
+	t.Helper()
+
+	c := config.DefaultConfig()
+	c.SelectedNetwork = ""
+	c.Hooks = map[string]string{}
+	for _, event := range hooks.Events {
+		c.Hooks[event] = "true"
+	}
+
+	var fired []hooks.Info
+	savedCfg, savedFire, savedDaemon := cfg, fireHook, daemonMode
+	cfg, daemonMode = c, true
+	fireHook = func(command string, info hooks.Info, timeout time.Duration) {
+		fired = append(fired, info)
+	}
+	currentState, currentStatusText = StateDisconnected, ""
+	stateRecorded, everConnected, hookConnected = false, false, false
+	t.Cleanup(func() {
+		cfg, fireHook, daemonMode = savedCfg, savedFire, savedDaemon
+	})
+
+	return func() []hooks.Info { return fired }
+}
+
+func hookEvents(fired []hooks.Info) []string {
+	var events []string
+	for _, info := range fired {
+		events = append(events, info.Event+" from "+info.PreviousState)
+	}
+	return events
+}
+
+func TestHooksForDrops(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func()
+		want  []string
+	}{
+		{
+			name: "reconnect through searching",
+			steps: func() {
+				updateState(StateConnected, "Connected to Quadmax", nil)
+				updateState(StateSearching, "Connecting to Quadmax...", nil)
+				updateState(StateConnected, "Connected to Quadmax", nil)
+			},
+			want: []string{"connected from disconnected", "disconnected from connected", "connected from searching"},
+		},
+		{
+			name: "failed reconnect",
+			steps: func() {
+				updateState(StateConnected, "Connected to Quadmax", nil)
+				updateState(StateSearching, "Connecting to Quadmax...", nil)
+				updateState(StateDisconnected, "Connection failed", nil)
+			},
+			want: []string{"connected from disconnected", "disconnected from connected"},
+		},
+		{
+			name: "direct drop",
+			steps: func() {
+				updateState(StateConnected, "Connected to Quadmax", nil)
+				updateState(StateDisconnected, "Quadmax not in range", nil)
+				updateState(StateDisconnected, "Quadmax not in range", nil)
+			},
+			want: []string{"connected from disconnected", "disconnected from connected"},
+		},
+		{
+			name: "connect now while connected",
+			steps: func() {
+				updateState(StateConnected, "Connected to Quadmax", nil)
+				updateStateOnRequest(StateSearching, "Connecting to Quadmax...", nil)
+				updateState(StateConnected, "Connected to Quadmax", nil)
+			},
+			want: []string{"connected from disconnected"},
+		},
+		{
+			name: "drop after leaving on request",
+			steps: func() {
+				updateState(StateConnected, "Connected to Quadmax", nil)
+				updateStateOnRequest(StateSearching, "Connecting to Quadmax...", nil)
+				updateState(StateDisconnected, "Connection failed", nil)
+			},
+			want: []string{"connected from disconnected", "disconnected from searching"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := recordHooks(t)
+			tt.steps()
+			if got := hookEvents(fired()); !slices.Equal(got, tt.want) {
+				t.Errorf("hooks fired %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
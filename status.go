@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/dashboard"
+	"github.com/whenry/quadmax-wifi-connector/events"
+	"github.com/whenry/quadmax-wifi-connector/metrics"
+	"github.com/whenry/quadmax-wifi-connector/mqtt"
+	"github.com/whenry/quadmax-wifi-connector/ui"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+var (
+	eventServer     *events.Server
+	dashboardServer *dashboard.Server
+
+	// The last error of the connection manager, shown in the settings
+	// window
+	lastError      string
+	lastErrorTime  time.Time
+	lastErrorMutex sync.Mutex
+)
+
+// noteError counts an error of the given kind for the metrics and
+// remembers it as the last error
+func noteError(kind string, err error) {
+	metrics.IncError(kind)
+
+	lastErrorMutex.Lock()
+	lastError = err.Error()
+	lastErrorTime = time.Now()
+	lastErrorMutex.Unlock()
+}
+
+// publishStatus passes the current state to the status file, the event
+// stream and the MQTT broker. status is the adapter status the state was
+// derived from, or nil to keep the last known signal.
+func publishStatus(status *wifi.ConnectionStatus) {
+	state, text := getState()
+	cfgMutex.RLock()
+	target := cfg.SelectedNetwork
+	cfgMutex.RUnlock()
+
+	s := events.Status{
+		Time:    time.Now(),
+		State:   state.String(),
+		Message: text,
+		Target:  target,
+		Paused:  isPaused(),
+	}
+	if state == StateConnected {
+		s.SSID = target
+		if status != nil {
+			s.Signal = status.SignalPercent()
+		} else if last, ok := events.Latest(); ok {
+			s.Signal = last.Signal
+		}
+	}
+	events.Publish(s)
+
+	if !daemonMode {
+		view := ui.Status{
+			State:   s.State,
+			Message: s.Message,
+			SSID:    s.SSID,
+			Signal:  s.Signal,
+			Paused:  s.Paused,
+		}
+		lastErrorMutex.Lock()
+		view.LastError = lastError
+		view.LastErrorTime = lastErrorTime
+		lastErrorMutex.Unlock()
+		if state != StateConnected && !s.Paused && target != "" {
+			view.NextRetry = time.Now().Add(pollInterval())
+		}
+		ui.UpdateStatus(view)
+	}
+
+	if mqttClient != nil {
+		mqttClient.Publish(mqtt.State{
+			State:     s.State,
+			Message:   s.Message,
+			SSID:      s.SSID,
+			Signal:    s.Signal,
+			Reachable: state == StateConnected && deviceReachable.Load(),
+			Paused:    s.Paused,
+		})
+	}
+}
+
+// startEventStream serves the event stream if it is enabled in the config
+func startEventStream() (*events.Server, error) {
+	cfgMutex.RLock()
+	enabled := cfg.EventStreamEnabled
+	port := cfg.EventStreamPort
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		return nil, nil
+	}
+	return events.Start(port)
+}
+
+// startDashboard serves the web dashboard if it is enabled in the config
+func startDashboard() (*dashboard.Server, error) {
+	cfgMutex.RLock()
+	enabled := cfg.DashboardEnabled
+	opts := dashboard.Options{
+		Port:    cfg.DashboardPort,
+		LAN:     cfg.DashboardLAN,
+		PIN:     cfg.DashboardPIN,
+		Host:    hostname,
+		Version: version,
+	}
+	cfgMutex.RUnlock()
+
+	if !enabled {
+		return nil, nil
+	}
+	return dashboard.Start(opts, appController{}, historyStore)
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"reflect"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+)
+
+// advancedForm edits the settings described by config.Fields, so new config
+// fields with a label tag show up without changes here
+type advancedForm struct {
+	content fyne.CanvasObject
+
+	fields []config.Field
+	values []func() string           // the text of each field's widget
+	shows  []func(value interface{}) // set each field's widget to a value
+}
+
+func newAdvancedForm() *advancedForm {
+	a := &advancedForm{fields: config.Fields()}
+
+	var groups []string
+	forms := map[string]*widget.Form{}
+	for _, f := range a.fields {
+		item, value, show := fieldWidget(f)
+		a.values = append(a.values, value)
+		a.shows = append(a.shows, show)
+
+		form, ok := forms[f.Group]
+		if !ok {
+			form = widget.NewForm()
+			forms[f.Group] = form
+			groups = append(groups, f.Group)
+		}
+		form.AppendItem(item)
+	}
+
+	cards := container.NewVBox()
+	for _, group := range groups {
+		cards.Add(createCard(group, forms[group]))
+	}
+	a.content = cards
+	return a
+}
+
+// fieldWidget creates the form item for a field, a function returning the
+// text to set the field from and a function showing a value of the field
+func fieldWidget(f config.Field) (*widget.FormItem, func() string, func(interface{})) {
+	label := f.Label
+	if f.Unit != "" {
+		label += " (" + f.Unit + ")"
+	}
+
+	switch f.Kind {
+	case reflect.Bool:
+		check := widget.NewCheck("", nil)
+		text := func() string { return strconv.FormatBool(check.Checked) }
+		show := func(value interface{}) { check.SetChecked(value.(bool)) }
+		return &widget.FormItem{Text: label, Widget: check, HintText: f.Help}, text, show
+
+	case reflect.Int:
+		entry := widget.NewEntry()
+		entry.Validator = func(text string) error {
+			_, err := f.ParseInt(text)
+			return err
+		}
+		text := func() string { return entry.Text }
+		show := func(value interface{}) { entry.SetText(strconv.Itoa(value.(int))) }
+		return &widget.FormItem{Text: label, Widget: entry, HintText: f.Help}, text, show
+	}
+
+	if len(f.Options) > 0 {
+		sel := widget.NewSelect(f.Options, nil)
+		text := func() string { return sel.Selected }
+		show := func(value interface{}) { sel.SetSelected(value.(string)) }
+		return &widget.FormItem{Text: label, Widget: sel, HintText: f.Help}, text, show
+	}
+
+	var entry *widget.Entry
+	switch f.Format {
+	case "password":
+		entry = widget.NewPasswordEntry()
+	case "clock":
+		entry = newClockEntry()
+	default:
+		entry = widget.NewEntry()
+	}
+	text := func() string { return entry.Text }
+	show := func(value interface{}) { entry.SetText(value.(string)) }
+	return &widget.FormItem{Text: label, Widget: entry, HintText: f.Help}, text, show
+}
+
+// set shows the fields of cfg in the form
+func (a *advancedForm) set(cfg *config.Config) {
+	for i, f := range a.fields {
+		a.shows[i](f.Get(cfg))
+	}
+}
+
+// apply sets the fields of cfg from the form
+func (a *advancedForm) apply(cfg *config.Config) error {
+	for i, f := range a.fields {
+		if err := f.Set(cfg, a.values[i]()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/whenry/quadmax-wifi-connector/history"
+)
+
+var (
+	historyWindow fyne.Window
+
+	// reloadHistory reloads the entries shown in the history window
+	reloadHistory func()
+)
+
+// Periods the history window can show
+var historyPeriods = map[string]time.Duration{
+	"Last 24 hours": 24 * time.Hour,
+	"Last 7 days":   7 * 24 * time.Hour,
+}
+
+const allStates = "All states"
+
+// Colors of the connection states in the history chart
+var stateColors = map[string]color.Color{
+	history.StateConnected:    color.NRGBA{R: 0x2E, G: 0xB8, B: 0x5C, A: 0xFF},
+	history.StateSearching:    color.NRGBA{R: 0xF0, G: 0xA2, B: 0x02, A: 0xFF},
+	history.StateDisconnected: color.NRGBA{R: 0xD9, G: 0x3F, B: 0x3F, A: 0xFF},
+}
+
+// ShowHistory displays the connection history recorded in store
+func ShowHistory(store *history.Store) {
+	if historyWindow != nil {
+		reloadHistory()
+		historyWindow.Show()
+		historyWindow.RequestFocus()
+		return
+	}
+
+	historyWindow = fyneApp.NewWindow("Connection History")
+	historyWindow.Resize(fyne.NewSize(720, 560))
+	historyWindow.CenterOnScreen()
+
+	// entries are loaded in the background and shown is read by the list,
+	// both are guarded by mu
+	var mu sync.Mutex
+	var entries, shown []history.Entry
+
+	periodSelect := widget.NewSelect([]string{"Last 24 hours", "Last 7 days"}, nil)
+	periodSelect.SetSelected("Last 24 hours")
+	stateSelect := widget.NewSelect([]string{allStates, history.StateConnected, history.StateSearching, history.StateDisconnected, history.StateStopped}, nil)
+	stateSelect.SetSelected(allStates)
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Filter by network or reason")
+
+	chart := newHistoryChart()
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+	load := newLoadIndicator()
+
+	list := widget.NewList(
+		func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(shown)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			mu.Lock()
+			if id >= len(shown) {
+				mu.Unlock()
+				return
+			}
+			e := shown[id]
+			mu.Unlock()
+			item.(*widget.Label).SetText(describeEntry(e))
+		},
+	)
+
+	// filter picks the transitions in the period matching the filters,
+	// newest first
+	filter := func() {
+		from := time.Now().Add(-historyPeriods[periodSelect.Selected])
+		search := strings.ToLower(strings.TrimSpace(searchEntry.Text))
+		mu.Lock()
+		transitions := history.Transitions(entries)
+		mu.Unlock()
+
+		var matching []history.Entry
+		for _, e := range transitions {
+			if e.Time.Before(from) {
+				continue
+			}
+			if stateSelect.Selected != allStates && e.State != stateSelect.Selected {
+				continue
+			}
+			if search != "" && !strings.Contains(strings.ToLower(e.SSID+" "+e.Reason), search) {
+				continue
+			}
+			matching = append(matching, e)
+		}
+		slices.Reverse(matching)
+
+		mu.Lock()
+		shown = matching
+		mu.Unlock()
+		list.Refresh()
+	}
+
+	// show updates the chart and summary for the period, then the list
+	show := func() {
+		now := time.Now()
+		from := now.Add(-historyPeriods[periodSelect.Selected])
+		mu.Lock()
+		loaded := entries
+		mu.Unlock()
+		chart.set(loaded, from, now)
+
+		stats := history.Compute(loaded, from, now)
+		text := fmt.Sprintf("%.1f%% uptime, %d drops", stats.Uptime(), stats.Drops)
+		if len(stats.Reconnects) > 0 {
+			text += fmt.Sprintf(", mean reconnect time %s", stats.MeanReconnect.Round(time.Second))
+		}
+		summary.SetText(text)
+		filter()
+	}
+
+	periodSelect.OnChanged = func(string) { show() }
+	stateSelect.OnChanged = func(string) { filter() }
+	searchEntry.OnChanged = func(string) { filter() }
+
+	var refreshBtn *widget.Button
+	reloadHistory = func() {
+		load.start()
+		refreshBtn.Disable()
+		go func() {
+			defer refreshBtn.Enable()
+			loaded, err := store.Load(time.Time{})
+			load.finish("connection history", err)
+			if err != nil {
+				slog.Warn("Could not load connection history", "error", err)
+				return
+			}
+			mu.Lock()
+			entries = loaded
+			mu.Unlock()
+			show()
+		}()
+	}
+	refreshBtn = widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() { reloadHistory() })
+
+	exportBtn := widget.NewButtonWithIcon("Export CSV", theme.DocumentSaveIcon(), func() {
+		mu.Lock()
+		export := slices.Clone(shown)
+		mu.Unlock()
+		slices.Reverse(export)
+		save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil || w == nil {
+				return
+			}
+			defer w.Close()
+			if err := history.WriteCSV(w, export); err != nil {
+				slog.Warn("Could not export connection history", "error", err)
+				dialog.ShowError(err, historyWindow)
+			}
+		}, historyWindow)
+		save.SetFileName("quadmax-history.csv")
+		save.Show()
+	})
+
+	legend := container.NewHBox()
+	for _, state := range []string{history.StateConnected, history.StateSearching, history.StateDisconnected} {
+		swatch := canvas.NewRectangle(stateColors[state])
+		swatch.SetMinSize(fyne.NewSize(12, 12))
+		legend.Add(container.NewCenter(swatch))
+		legend.Add(widget.NewLabel(state))
+	}
+	signalSwatch := canvas.NewRectangle(theme.PrimaryColor())
+	signalSwatch.SetMinSize(fyne.NewSize(12, 3))
+	legend.Add(container.NewCenter(signalSwatch))
+	legend.Add(widget.NewLabel("signal"))
+
+	filters := container.NewBorder(nil, nil,
+		container.NewHBox(periodSelect, stateSelect),
+		container.NewHBox(refreshBtn, exportBtn),
+		searchEntry,
+	)
+
+	top := container.NewVBox(
+		filters,
+		load.content,
+		chart,
+		container.NewHBox(legend, layout.NewSpacer()),
+		summary,
+		widget.NewSeparator(),
+	)
+
+	historyWindow.SetContent(container.NewPadded(container.NewBorder(top, nil, nil, nil, list)))
+	historyWindow.SetCloseIntercept(func() {
+		historyWindow.Hide()
+	})
+
+	reloadHistory()
+	historyWindow.Show()
+}
+
+// describeEntry formats a history entry for the list
+func describeEntry(e history.Entry) string {
+	text := fmt.Sprintf("%s  %-12s  %s", e.Time.Format("2006-01-02 15:04:05"), e.State, e.Reason)
+	if e.SSID != "" {
+		text += fmt.Sprintf(" [%s %d%%]", e.SSID, e.Signal)
+	}
+	return text
+}
+
+// historyChart draws the connection state as colored spans along the
+// bottom and the signal strength as a line above them
+type historyChart struct {
+	widget.BaseWidget
+
+	entries  []history.Entry
+	from, to time.Time
+}
+
+func newHistoryChart() *historyChart {
+	c := &historyChart{}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// set shows entries between from and to
+func (c *historyChart) set(entries []history.Entry, from, to time.Time) {
+	c.entries, c.from, c.to = entries, from, to
+	c.Refresh()
+}
+
+func (c *historyChart) CreateRenderer() fyne.WidgetRenderer {
+	return &historyChartRenderer{chart: c}
+}
+
+type historyChartRenderer struct {
+	chart   *historyChart
+	objects []fyne.CanvasObject
+}
+
+const (
+	chartBandHeight = 14
+	chartAxisHeight = 16
+)
+
+func (r *historyChartRenderer) Layout(size fyne.Size) {
+	c := r.chart
+	plotHeight := size.Height - chartBandHeight - chartAxisHeight
+	background := canvas.NewRectangle(theme.InputBackgroundColor())
+	background.Resize(fyne.NewSize(size.Width, plotHeight+chartBandHeight))
+	midline := canvas.NewLine(theme.DisabledColor())
+	midline.Position1 = fyne.NewPos(0, plotHeight/2)
+	midline.Position2 = fyne.NewPos(size.Width, plotHeight/2)
+	objects := []fyne.CanvasObject{background, midline}
+
+	span := c.to.Sub(c.from)
+	if span <= 0 {
+		r.objects = objects
+		return
+	}
+	x := func(t time.Time) float32 {
+		if t.Before(c.from) {
+			t = c.from
+		}
+		return size.Width * float32(t.Sub(c.from)) / float32(span)
+	}
+	y := func(signal int) float32 {
+		return plotHeight * (1 - float32(signal)/100)
+	}
+
+	var last fyne.Position
+	haveLast := false
+	for i, e := range c.entries {
+		end := c.to
+		if i+1 < len(c.entries) {
+			end = c.entries[i+1].Time
+		}
+		if end.Before(c.from) {
+			continue
+		}
+
+		// Each state lasts until the next entry, nothing is known after
+		// the app stopped
+		if fill, ok := stateColors[e.State]; ok {
+			band := canvas.NewRectangle(fill)
+			band.Move(fyne.NewPos(x(e.Time), plotHeight))
+			band.Resize(fyne.NewSize(x(end)-x(e.Time), chartBandHeight))
+			objects = append(objects, band)
+		}
+
+		// Signal line through the connected readings
+		if e.State != history.StateConnected || e.Signal <= 0 {
+			haveLast = false
+			continue
+		}
+		point := fyne.NewPos(x(e.Time), y(e.Signal))
+		if haveLast {
+			line := canvas.NewLine(theme.PrimaryColor())
+			line.StrokeWidth = 2
+			line.Position1, line.Position2 = last, point
+			objects = append(objects, line)
+		}
+		last, haveLast = point, true
+	}
+
+	// Time axis
+	format := "15:04"
+	if span > 24*time.Hour {
+		format = "Jan 2 15:04"
+	}
+	for _, t := range []time.Time{c.from, c.from.Add(span / 2), c.to} {
+		label := canvas.NewText(t.Format(format), theme.ForegroundColor())
+		label.TextSize = theme.CaptionTextSize()
+		labelSize := label.MinSize()
+		left := x(t) - labelSize.Width/2
+		left = max(0, min(left, size.Width-labelSize.Width))
+		label.Move(fyne.NewPos(left, plotHeight+chartBandHeight))
+		objects = append(objects, label)
+	}
+
+	r.objects = objects
+}
+
+func (r *historyChartRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(300, 160)
+}
+
+func (r *historyChartRenderer) Refresh() {
+	r.Layout(r.chart.Size())
+	canvas.Refresh(r.chart)
+}
+
+func (r *historyChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *historyChartRenderer) Destroy() {}
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	loadMutex  sync.Mutex
+	loadCtx    context.Context
+	loadCancel context.CancelFunc
+)
+
+// loadContext returns the context for loads started by the settings
+// window. It is cancelled when the window is closed.
+func loadContext() context.Context {
+	loadMutex.Lock()
+	defer loadMutex.Unlock()
+	if loadCtx == nil || loadCtx.Err() != nil {
+		loadCtx, loadCancel = context.WithCancel(context.Background())
+	}
+	return loadCtx
+}
+
+// cancelLoads stops the loads started by the settings window
+func cancelLoads() {
+	loadMutex.Lock()
+	defer loadMutex.Unlock()
+	if loadCancel != nil {
+		loadCancel()
+	}
+}
+
+// loadIndicator shows a progress bar while something loads, then the error
+// if it failed
+type loadIndicator struct {
+	content  fyne.CanvasObject
+	progress *widget.ProgressBarInfinite
+	err      *widget.Label
+}
+
+func newLoadIndicator() *loadIndicator {
+	l := &loadIndicator{
+		progress: widget.NewProgressBarInfinite(),
+		err:      widget.NewLabel(""),
+	}
+	l.err.Wrapping = fyne.TextWrapWord
+	l.err.Importance = widget.DangerImportance
+	l.progress.Hide()
+	l.err.Hide()
+	l.content = container.NewVBox(l.progress, l.err)
+	return l
+}
+
+func (l *loadIndicator) start() {
+	l.err.Hide()
+	l.progress.Show()
+	l.progress.Start()
+}
+
+// finish hides the progress bar and shows err, if any, for loading what
+func (l *loadIndicator) finish(what string, err error) {
+	l.progress.Stop()
+	l.progress.Hide()
+	if err != nil {
+		l.err.SetText("Could not load " + what + ": " + err.Error())
+		l.err.Show()
+	}
+}
+
+// setOptions replaces the options of sel and selects the first of
+// preferred that is among them
+func setOptions(sel *widget.Select, options []string, preferred ...string) {
+	sel.Options = options
+	sel.Refresh()
+	for _, option := range preferred {
+		if option != "" && slices.Contains(options, option) {
+			sel.SetSelected(option)
+			return
+		}
+	}
+	sel.ClearSelected()
+}
+
+// first returns the first of options, or "" if there are none
+func first(options []string) string {
+	if len(options) == 0 {
+		return ""
+	}
+	return options[0]
+}
@@ -0,0 +1,391 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// scanInterval is how often the scanner refreshes while auto-refresh is on
+const scanInterval = 10 * time.Second
+
+// connectToNetwork connects to a saved network right away
+var connectToNetwork func(network string) error
+
+// SetConnectHandler sets the action behind the scanner's Connect button
+func SetConnectHandler(handler func(network string) error) {
+	connectToNetwork = handler
+}
+
+// scanColumn is a column of the scanner table
+type scanColumn struct {
+	title string
+	width float32
+	text  func(n scanNetwork) string
+	order func(a, b scanNetwork) int
+}
+
+// scanNetwork is a visible network and whether a saved profile exists for it
+type scanNetwork struct {
+	wifi.NetworkDetail
+	saved bool
+}
+
+const signalColumn = 1
+
+var scanColumns = []scanColumn{
+	{"Network", 140, func(n scanNetwork) string { return n.SSID },
+		func(a, b scanNetwork) int { return cmp.Compare(strings.ToLower(a.SSID), strings.ToLower(b.SSID)) }},
+	{"Signal", 90, nil,
+		func(a, b scanNetwork) int { return cmp.Compare(a.Signal, b.Signal) }},
+	{"Band", 70, func(n scanNetwork) string { return n.Band },
+		func(a, b scanNetwork) int { return cmp.Compare(a.Band, b.Band) }},
+	{"Channel", 70, func(n scanNetwork) string { return strconv.Itoa(n.Channel) },
+		func(a, b scanNetwork) int { return cmp.Compare(a.Channel, b.Channel) }},
+	{"Security", 120, func(n scanNetwork) string { return n.Security },
+		func(a, b scanNetwork) int { return cmp.Compare(a.Security, b.Security) }},
+	{"Saved", 60, func(n scanNetwork) string {
+		if n.saved {
+			return "Yes"
+		}
+		return ""
+	}, func(a, b scanNetwork) int {
+		if a.saved == b.saved {
+			return 0
+		}
+		if a.saved {
+			return 1
+		}
+		return -1
+	}},
+}
+
+// scanner is the settings tab listing the visible networks
+type scanner struct {
+	content fyne.CanvasObject
+
+	adapter     func() string
+	onSetTarget func(network string)
+
+	table        *widget.Table
+	load         *loadIndicator
+	autoRefresh  *widget.Check
+	updated      *widget.Label
+	message      *widget.Label
+	setTargetBtn *widget.Button
+	connectBtn   *widget.Button
+
+	mu         sync.Mutex
+	networks   []scanNetwork
+	sortColumn int
+	descending bool
+	selected   string
+	active     bool
+	scanning   bool
+	loopCtx    context.Context
+}
+
+// newScanner creates the scanner tab. adapter returns the adapter to scan
+// with and onSetTarget makes a network the target.
+func newScanner(adapter func() string, onSetTarget func(network string)) *scanner {
+	s := &scanner{
+		adapter:     adapter,
+		onSetTarget: onSetTarget,
+		load:        newLoadIndicator(),
+		updated:     widget.NewLabel(""),
+		message:     widget.NewLabel(""),
+		sortColumn:  signalColumn,
+		descending:  true,
+	}
+	s.message.Wrapping = fyne.TextWrapWord
+
+	s.table = widget.NewTable(s.size, s.createCell, s.updateCell)
+	s.table.ShowHeaderRow = true
+	s.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewButton("", nil)
+	}
+	s.table.UpdateHeader = s.updateHeader
+	s.table.OnSelected = s.selectRow
+	for i, column := range scanColumns {
+		s.table.SetColumnWidth(i, column.width)
+	}
+
+	s.autoRefresh = widget.NewCheck("Auto-refresh", nil)
+	s.autoRefresh.SetChecked(true)
+	refreshBtn := widget.NewButtonWithIcon("Scan", theme.ViewRefreshIcon(), s.refresh)
+
+	s.setTargetBtn = widget.NewButtonWithIcon("Set as target", theme.ConfirmIcon(), func() {
+		if network := s.selection(); network != "" {
+			s.onSetTarget(network)
+			s.message.SetText(network + " is now the target network")
+		}
+	})
+	s.connectBtn = widget.NewButtonWithIcon("Connect", theme.LoginIcon(), s.connect)
+	s.connectBtn.Importance = widget.HighImportance
+	s.setTargetBtn.Disable()
+	s.connectBtn.Disable()
+
+	s.content = container.NewBorder(
+		container.NewVBox(
+			container.NewHBox(refreshBtn, s.autoRefresh, layout.NewSpacer(), s.updated),
+			s.load.content,
+		),
+		container.NewVBox(
+			s.message,
+			container.NewHBox(layout.NewSpacer(), s.setTargetBtn, s.connectBtn),
+		),
+		nil,
+		nil,
+		s.table,
+	)
+	return s
+}
+
+func (s *scanner) size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.networks), len(scanColumns)
+}
+
+func (s *scanner) createCell() fyne.CanvasObject {
+	signal := widget.NewProgressBar()
+	return container.NewStack(widget.NewLabel(""), signal)
+}
+
+func (s *scanner) updateCell(id widget.TableCellID, cell fyne.CanvasObject) {
+	s.mu.Lock()
+	if id.Row >= len(s.networks) {
+		s.mu.Unlock()
+		return
+	}
+	network := s.networks[id.Row]
+	s.mu.Unlock()
+
+	objects := cell.(*fyne.Container).Objects
+	label, signal := objects[0].(*widget.Label), objects[1].(*widget.ProgressBar)
+	if id.Col == signalColumn {
+		label.Hide()
+		signal.SetValue(float64(network.Signal) / 100)
+		signal.Show()
+		return
+	}
+	signal.Hide()
+	label.SetText(scanColumns[id.Col].text(network))
+	label.Show()
+}
+
+// updateHeader shows the column titles, marking the sort column. Tapping a
+// title sorts by it, or reverses the order if it already is the sort column.
+func (s *scanner) updateHeader(id widget.TableCellID, cell fyne.CanvasObject) {
+	button := cell.(*widget.Button)
+	s.mu.Lock()
+	title := scanColumns[id.Col].title
+	if id.Col == s.sortColumn {
+		if s.descending {
+			title += " ▼"
+		} else {
+			title += " ▲"
+		}
+	}
+	s.mu.Unlock()
+
+	button.SetText(title)
+	button.OnTapped = func() {
+		s.mu.Lock()
+		if s.sortColumn == id.Col {
+			s.descending = !s.descending
+		} else {
+			s.sortColumn = id.Col
+			s.descending = id.Col == signalColumn
+		}
+		s.sortLocked()
+		s.mu.Unlock()
+		s.show()
+	}
+}
+
+// sortLocked sorts the networks by the sort column, strongest signal first
+// among equals. s.mu must be held.
+func (s *scanner) sortLocked() {
+	order := scanColumns[s.sortColumn].order
+	slices.SortStableFunc(s.networks, func(a, b scanNetwork) int {
+		c := order(a, b)
+		if s.descending {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(b.Signal, a.Signal)
+		}
+		return c
+	})
+}
+
+// show refreshes the table, keeping the selected network selected
+func (s *scanner) show() {
+	s.mu.Lock()
+	row := slices.IndexFunc(s.networks, func(n scanNetwork) bool { return n.SSID == s.selected })
+	s.mu.Unlock()
+
+	s.table.Refresh()
+	if row >= 0 {
+		s.table.Select(widget.TableCellID{Row: row, Col: 0})
+	} else {
+		s.table.UnselectAll()
+		s.selectRow(widget.TableCellID{Row: -1})
+	}
+}
+
+// selectRow enables the actions that apply to the network in row
+func (s *scanner) selectRow(id widget.TableCellID) {
+	s.mu.Lock()
+	var network scanNetwork
+	if id.Row >= 0 && id.Row < len(s.networks) {
+		network = s.networks[id.Row]
+	}
+	s.selected = network.SSID
+	s.mu.Unlock()
+
+	if network.saved {
+		s.setTargetBtn.Enable()
+		s.connectBtn.Enable()
+		return
+	}
+	s.setTargetBtn.Disable()
+	s.connectBtn.Disable()
+	if network.SSID != "" {
+		s.message.SetText("There is no saved profile for " + network.SSID + ". Connect to it once from Windows first.")
+	}
+}
+
+func (s *scanner) selection() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selected
+}
+
+// setActive records whether the scanner tab is showing, scanning right away
+// when it comes into view
+func (s *scanner) setActive(active bool) {
+	s.mu.Lock()
+	s.active = active
+	s.mu.Unlock()
+	if active {
+		s.refresh()
+	}
+}
+
+// start runs the auto-refresh loop until the settings window is closed
+func (s *scanner) start() {
+	ctx := loadContext()
+	s.mu.Lock()
+	running := s.loopCtx == ctx
+	s.loopCtx = ctx
+	active := s.active
+	s.mu.Unlock()
+	if running {
+		return
+	}
+
+	if active {
+		s.refresh()
+	}
+	go func() {
+		ticker := time.NewTicker(scanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				active := s.active
+				s.mu.Unlock()
+				if active && s.autoRefresh.Checked {
+					s.refresh()
+				}
+			}
+		}
+	}()
+}
+
+// refresh scans for networks in the background
+func (s *scanner) refresh() {
+	s.mu.Lock()
+	if s.scanning {
+		s.mu.Unlock()
+		return
+	}
+	s.scanning = true
+	s.mu.Unlock()
+
+	ctx := loadContext()
+	s.load.start()
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.scanning = false
+			s.mu.Unlock()
+		}()
+
+		details, err := wifi.ScanNetworksDetailed(ctx, s.adapter())
+		var profiles []string
+		if err == nil {
+			profiles, err = wifi.GetSavedProfilesContext(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.load.finish("networks", err)
+		if err != nil {
+			slog.Warn("Could not scan for networks", "error", err)
+			return
+		}
+
+		networks := make([]scanNetwork, len(details))
+		for i, detail := range details {
+			networks[i] = scanNetwork{NetworkDetail: detail, saved: slices.Contains(profiles, detail.SSID)}
+		}
+
+		s.mu.Lock()
+		s.networks = networks
+		s.sortLocked()
+		s.mu.Unlock()
+
+		s.updated.SetText(fmt.Sprintf("%d networks, %s", len(networks), time.Now().Format("15:04:05")))
+		s.show()
+	}()
+}
+
+// connect connects to the selected network
+func (s *scanner) connect() {
+	network := s.selection()
+	if network == "" || connectToNetwork == nil {
+		return
+	}
+
+	s.connectBtn.Disable()
+	s.message.SetText("Connecting to " + network + "...")
+	go func() {
+		defer s.connectBtn.Enable()
+		if err := connectToNetwork(network); err != nil {
+			s.message.SetText("Error: " + err.Error())
+			return
+		}
+		s.message.SetText("Connected to " + network)
+		s.refresh()
+	}()
+}
@@ -1,7 +1,14 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
 	"image/color"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -12,12 +19,40 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/history"
 	"github.com/whenry/quadmax-wifi-connector/wifi"
 )
 
 var (
 	fyneApp    fyne.App
 	mainWindow fyne.Window
+
+	// reloadSettings refreshes the settings window from the current config
+	// and reloads the adapters and profiles it shows
+	reloadSettings func()
+
+	// settingsConfig is the config shown in the settings window. It is
+	// never changed in place, saving edits a clone.
+	settingsConfig *config.Config
+	settingsOnSave func(*config.Config)
+	settingsMutex  sync.Mutex
+
+	// settingsHistory is the connection history summarized in the settings
+	// window
+	settingsHistory *history.Store
+)
+
+// createDiagnostics writes a diagnostics bundle and returns its path
+var createDiagnostics func() (string, error)
+
+// SetDiagnosticsHandler sets the action behind the diagnostics button
+func SetDiagnosticsHandler(handler func() (string, error)) {
+	createDiagnostics = handler
+}
+
+var (
+	errInvalidSeconds = errors.New("enter a whole number of seconds")
+	errInvalidClock   = errors.New("enter a time as HH:MM")
 )
 
 // Custom theme for a more polished look
@@ -85,163 +120,425 @@ func createCard(title string, content fyne.CanvasObject) fyne.CanvasObject {
 	return container.NewPadded(cardContent)
 }
 
-// ShowSettings displays the settings window
-func ShowSettings(cfg *config.Config, onSave func(*config.Config)) {
+// newClockEntry creates an entry for a time of day as HH:MM
+func newClockEntry() *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("HH:MM")
+	entry.Validator = func(text string) error {
+		if _, err := time.Parse("15:04", text); err != nil {
+			return errInvalidClock
+		}
+		return nil
+	}
+	return entry
+}
+
+// shownSettings returns the config shown in the settings window
+func shownSettings() *config.Config {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	return settingsConfig
+}
+
+// saveSettings saves c and makes it the config shown in the settings window
+func saveSettings(c *config.Config) error {
+	if err := c.Save(); err != nil {
+		return err
+	}
+
+	settingsMutex.Lock()
+	settingsConfig = c
+	onSave := settingsOnSave
+	settingsMutex.Unlock()
+
+	if onSave != nil {
+		onSave(c)
+	}
+	return nil
+}
+
+// ShowSettings displays the settings window for cfg and the history in
+// store, which may be nil. onSave gets a new config each time the settings
+// are saved.
+func ShowSettings(cfg *config.Config, store *history.Store, onSave func(*config.Config)) {
+	settingsMutex.Lock()
+	settingsConfig = cfg.Clone()
+	settingsOnSave = onSave
+	settingsMutex.Unlock()
+	settingsHistory = store
+
 	if mainWindow != nil {
+		reloadSettings()
 		mainWindow.Show()
 		mainWindow.RequestFocus()
 		return
 	}
 
 	mainWindow = fyneApp.NewWindow("Quadmax WiFi Connector")
-	mainWindow.Resize(fyne.NewSize(450, 420))
+	mainWindow.Resize(fyne.NewSize(600, 640))
 	mainWindow.CenterOnScreen()
 
-	// Get available adapters
-	adapters, err := wifi.GetAdapters()
-	adapterNames := []string{}
-	if err == nil {
-		for _, a := range adapters {
-			adapterNames = append(adapterNames, a.Name)
-		}
-	}
-
-	// Get saved WiFi profiles
-	profiles, err := wifi.GetSavedProfiles()
-	if err != nil {
-		profiles = []string{}
-	}
+	// Adapters and profiles are filled in by the loads started below
+	var loadAdapters, loadProfiles func()
 
 	// Adapter section
-	adapterSelect := widget.NewSelect(adapterNames, nil)
+	adapterSelect := widget.NewSelect(nil, nil)
 	adapterSelect.PlaceHolder = "Select a network adapter..."
-	if cfg.SelectedAdapter != "" {
-		adapterSelect.SetSelected(cfg.SelectedAdapter)
-	} else if len(adapterNames) > 0 {
-		adapterSelect.SetSelected(adapterNames[0])
-	}
+
+	internetAdapterSelect := widget.NewSelect(nil, nil)
+	internetAdapterSelect.PlaceHolder = "Select the internet adapter..."
 
 	refreshAdaptersBtn := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
-		adapters, err := wifi.GetAdapters()
-		if err == nil {
-			names := []string{}
-			for _, a := range adapters {
-				names = append(names, a.Name)
-			}
-			adapterSelect.Options = names
-			adapterSelect.Refresh()
-		}
+		loadAdapters()
 	})
 
+	adapterLoad := newLoadIndicator()
 	adapterRow := container.NewBorder(nil, nil, nil, refreshAdaptersBtn, adapterSelect)
 	adapterHelp := widget.NewLabelWithStyle("Choose the wireless adapter to use for connecting", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
 	adapterHelp.Wrapping = fyne.TextWrapWord
-	adapterSection := container.NewVBox(adapterRow, adapterHelp)
+	adapterSection := container.NewVBox(adapterRow, adapterLoad.content, adapterHelp)
 
 	// Network section
-	networkSelect := widget.NewSelect(profiles, nil)
+	networkSelect := widget.NewSelect(nil, nil)
 	networkSelect.PlaceHolder = "Select a saved network profile..."
-	if cfg.SelectedNetwork != "" {
-		networkSelect.SetSelected(cfg.SelectedNetwork)
-	}
+
+	internetNetworkSelect := widget.NewSelect(nil, nil)
+	internetNetworkSelect.PlaceHolder = "Select the internet network profile..."
 
 	refreshNetworksBtn := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
-		profiles, err := wifi.GetSavedProfiles()
-		if err == nil {
-			networkSelect.Options = profiles
-			networkSelect.Refresh()
-		}
+		loadProfiles()
 	})
 
+	profileLoad := newLoadIndicator()
 	networkRow := container.NewBorder(nil, nil, nil, refreshNetworksBtn, networkSelect)
 	networkHelp := widget.NewLabelWithStyle("Select your Quadmax launch monitor network", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
 	networkHelp.Wrapping = fyne.TextWrapWord
-	networkSection := container.NewVBox(networkRow, networkHelp)
-
-	// Status indicator
-	statusIcon := canvas.NewCircle(color.NRGBA{R: 100, G: 100, B: 100, A: 255})
-	statusIcon.Resize(fyne.NewSize(12, 12))
-	statusLabel := widget.NewLabel("Checking connection status...")
-
-	statusRow := container.NewHBox(statusIcon, statusLabel)
-
-	// Update status based on current connection
-	go func() {
-		if cfg.SelectedAdapter != "" {
-			status, err := wifi.GetConnectionStatus(cfg.SelectedAdapter)
-			if err == nil && status.Connected && status.SSID == cfg.SelectedNetwork {
-				statusIcon.FillColor = color.NRGBA{R: 0x00, G: 0xC8, B: 0x00, A: 0xFF}
-				statusLabel.SetText("Connected to " + status.SSID)
-			} else if err == nil && status.Connected {
-				statusIcon.FillColor = color.NRGBA{R: 0xFF, G: 0xC8, B: 0x00, A: 0xFF}
-				statusLabel.SetText("Connected to " + status.SSID + " (not target)")
-			} else {
-				statusIcon.FillColor = color.NRGBA{R: 0xE0, G: 0x00, B: 0x00, A: 0xFF}
-				statusLabel.SetText("Not connected to target network")
-			}
-			statusIcon.Refresh()
+	networkSection := container.NewVBox(networkRow, profileLoad.content, networkHelp)
+
+	// Fallback section
+	gracePeriodEntry := widget.NewEntry()
+	gracePeriodEntry.Validator = func(text string) error {
+		if seconds, err := strconv.Atoi(text); err != nil || seconds <= 0 {
+			return errInvalidSeconds
+		}
+		return nil
+	}
+
+	restoreCheck := widget.NewCheck("Reconnect to the previous network when the Quadmax is out of range", func(checked bool) {
+		if checked {
+			gracePeriodEntry.Enable()
 		} else {
-			statusLabel.SetText("No adapter selected")
+			gracePeriodEntry.Disable()
 		}
-	}()
+	})
+
+	gracePeriodRow := container.NewBorder(nil, nil, widget.NewLabel("Wait (seconds):"), nil, gracePeriodEntry)
+	fallbackSection := container.NewVBox(restoreCheck, gracePeriodRow)
+
+	// Internet adapter section
+	dualAdapterCheck := widget.NewCheck("Keep a second adapter connected to the internet", func(checked bool) {
+		if checked {
+			internetAdapterSelect.Enable()
+			internetNetworkSelect.Enable()
+		} else {
+			internetAdapterSelect.Disable()
+			internetNetworkSelect.Disable()
+		}
+	})
+
+	internetHelp := widget.NewLabelWithStyle("The adapter above stays dedicated to the Quadmax", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	internetHelp.Wrapping = fyne.TextWrapWord
+	internetSection := container.NewVBox(dualAdapterCheck, internetAdapterSelect, internetNetworkSelect, internetHelp)
+
+	// Status card, kept current by UpdateStatus
+	status := newStatusCard()
+
+	// Connection history summary
+	historyLabel := widget.NewLabel("Loading connection history...")
+	historyLabel.Wrapping = fyne.TextWrapWord
 
 	// Message label for feedback
 	messageLabel := widget.NewLabel("")
 	messageLabel.Alignment = fyne.TextAlignCenter
+	messageLabel.Wrapping = fyne.TextWrapWord
+
+	// Advanced tab, generated from the config field tags
+	advanced := newAdvancedForm()
 
 	// Action buttons
 	saveBtn := widget.NewButtonWithIcon("Save Settings", theme.DocumentSaveIcon(), func() {
+		if err := gracePeriodEntry.Validate(); err != nil {
+			messageLabel.SetText("Error: " + err.Error())
+			return
+		}
+
+		current := shownSettings()
+		cfg := current.Clone()
 		cfg.SelectedAdapter = adapterSelect.Selected
 		cfg.SelectedNetwork = networkSelect.Selected
+		if dualAdapterCheck.Checked && internetAdapterSelect.Selected == adapterSelect.Selected {
+			messageLabel.SetText("Error: choose two different adapters")
+			return
+		}
+
+		cfg.RestorePreviousNetwork = restoreCheck.Checked
+		cfg.RestoreGracePeriod, _ = strconv.Atoi(gracePeriodEntry.Text)
+		cfg.DualAdapterMode = dualAdapterCheck.Checked
+		cfg.InternetAdapter = internetAdapterSelect.Selected
+		cfg.InternetNetwork = internetNetworkSelect.Selected
 
-		if err := cfg.Save(); err != nil {
+		if err := advanced.apply(cfg); err != nil {
+			messageLabel.SetText("Error: " + err.Error())
+			return
+		}
+		if err := cfg.Validate(); err != nil {
 			messageLabel.SetText("Error: " + err.Error())
 			return
 		}
 
-		messageLabel.SetText("Settings saved successfully!")
-		if onSave != nil {
-			onSave(cfg)
+		if err := saveSettings(cfg); err != nil {
+			slog.Error("Could not save settings", "error", err)
+			messageLabel.SetText("Error: " + err.Error())
+			return
 		}
+		messageLabel.SetText("Settings saved successfully!")
 	})
 	saveBtn.Importance = widget.HighImportance
 
-	closeBtn := widget.NewButtonWithIcon("Close", theme.CancelIcon(), func() {
-		mainWindow.Hide()
-	})
+	diagnosticsBtn := widget.NewButtonWithIcon("Diagnostics", theme.DocumentCreateIcon(), nil)
+	diagnosticsBtn.OnTapped = func() {
+		if createDiagnostics == nil {
+			return
+		}
+		diagnosticsBtn.Disable()
+		messageLabel.SetText("Creating diagnostics bundle...")
+		go func() {
+			defer diagnosticsBtn.Enable()
+			path, err := createDiagnostics()
+			if err != nil {
+				messageLabel.SetText("Error: " + err.Error())
+				return
+			}
+			messageLabel.SetText("Diagnostics saved to " + path)
+		}()
+	}
+
+	closeBtn := widget.NewButtonWithIcon("Close", theme.CancelIcon(), hideSettings)
 
 	buttonRow := container.NewHBox(
+		diagnosticsBtn,
 		layout.NewSpacer(),
 		closeBtn,
 		saveBtn,
 	)
 
 	// Build the main content
-	content := container.NewVBox(
-		createHeader(),
-		container.NewPadded(
-			container.NewVBox(
-				createCard("Network Adapter", adapterSection),
-				createCard("Target Network", networkSection),
-				createCard("Status", statusRow),
-				widget.NewSeparator(),
-				messageLabel,
-				buttonRow,
-			),
+	cards := container.NewVBox(
+		createCard("Network Adapter", adapterSection),
+		createCard("Target Network", networkSection),
+		createCard("Fallback Network", fallbackSection),
+		createCard("Internet Adapter", internetSection),
+		createCard("Status", status.content),
+		createCard("Connection History", historyLabel),
+	)
+	footer := container.NewPadded(
+		container.NewVBox(
+			widget.NewSeparator(),
+			messageLabel,
+			buttonRow,
 		),
 	)
 
-	mainWindow.SetContent(content)
-	mainWindow.SetCloseIntercept(func() {
-		mainWindow.Hide()
+	// Scanner tab
+	scan := newScanner(func() string {
+		if adapterSelect.Selected != "" {
+			return adapterSelect.Selected
+		}
+		current := shownSettings()
+		return current.SelectedAdapter
+	}, func(network string) {
+		networkSelect.SetSelected(network)
+		current := shownSettings()
+		cfg := current.Clone()
+		cfg.SelectedNetwork = network
+		if err := saveSettings(cfg); err != nil {
+			slog.Error("Could not save settings", "error", err)
+			messageLabel.SetText("Error: " + err.Error())
+		}
 	})
 
+	settingsTab := container.NewTabItem("Settings", container.NewVScroll(container.NewPadded(cards)))
+	advancedTab := container.NewTabItem("Advanced", container.NewVScroll(container.NewPadded(advanced.content)))
+	scannerTab := container.NewTabItem("Scanner", container.NewPadded(scan.content))
+	tabs := container.NewAppTabs(settingsTab, advancedTab, scannerTab)
+	tabs.OnSelected = func(tab *container.TabItem) {
+		// The scanner has its own actions
+		if tab == scannerTab {
+			footer.Hide()
+		} else {
+			footer.Show()
+		}
+		scan.setActive(tab == scannerTab)
+	}
+
+	content := container.NewBorder(
+		createHeader(),
+		footer,
+		nil,
+		nil,
+		tabs,
+	)
+
+	// Saving is held off while loads are running, as the selects are
+	// still empty
+	var pending atomic.Int32
+	beginLoad := func() {
+		if pending.Add(1) == 1 {
+			saveBtn.Disable()
+		}
+	}
+	endLoad := func() {
+		if pending.Add(-1) == 0 {
+			saveBtn.Enable()
+		}
+	}
+
+	loadAdapters = func() {
+		cfg := shownSettings()
+		ctx := loadContext()
+		beginLoad()
+		adapterSelect.Disable()
+		internetAdapterSelect.Disable()
+		refreshAdaptersBtn.Disable()
+		adapterLoad.start()
+
+		go func() {
+			defer endLoad()
+			adapters, err := wifi.GetAdaptersContext(ctx)
+			if ctx.Err() != nil {
+				// The window was closed, the next open loads again
+				return
+			}
+
+			adapterLoad.finish("adapters", err)
+			refreshAdaptersBtn.Enable()
+			adapterSelect.Enable()
+			if dualAdapterCheck.Checked {
+				internetAdapterSelect.Enable()
+			}
+			if err != nil {
+				slog.Warn("Could not list adapters", "error", err)
+				return
+			}
+
+			names := []string{}
+			for _, a := range adapters {
+				names = append(names, a.Name)
+			}
+			setOptions(adapterSelect, names, adapterSelect.Selected, cfg.SelectedAdapter, first(names))
+			setOptions(internetAdapterSelect, names, internetAdapterSelect.Selected, cfg.InternetAdapter)
+		}()
+	}
+
+	loadProfiles = func() {
+		cfg := shownSettings()
+		ctx := loadContext()
+		beginLoad()
+		networkSelect.Disable()
+		internetNetworkSelect.Disable()
+		refreshNetworksBtn.Disable()
+		profileLoad.start()
+
+		go func() {
+			defer endLoad()
+			profiles, err := wifi.GetSavedProfilesContext(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			profileLoad.finish("saved networks", err)
+			refreshNetworksBtn.Enable()
+			networkSelect.Enable()
+			if dualAdapterCheck.Checked {
+				internetNetworkSelect.Enable()
+			}
+			if err != nil {
+				slog.Warn("Could not list saved profiles", "error", err)
+				return
+			}
+
+			setOptions(networkSelect, profiles, networkSelect.Selected, cfg.SelectedNetwork)
+			setOptions(internetNetworkSelect, profiles, internetNetworkSelect.Selected, cfg.InternetNetwork)
+		}()
+	}
+
+	// fill shows the values of the current config, dropping unsaved edits
+	fill := func() {
+		cfg := shownSettings()
+		setOptions(adapterSelect, adapterSelect.Options, cfg.SelectedAdapter)
+		setOptions(networkSelect, networkSelect.Options, cfg.SelectedNetwork)
+		setOptions(internetAdapterSelect, internetAdapterSelect.Options, cfg.InternetAdapter)
+		setOptions(internetNetworkSelect, internetNetworkSelect.Options, cfg.InternetNetwork)
+		gracePeriodEntry.SetText(strconv.Itoa(cfg.RestoreGracePeriod))
+		restoreCheck.SetChecked(cfg.RestorePreviousNetwork)
+		restoreCheck.OnChanged(cfg.RestorePreviousNetwork)
+		dualAdapterCheck.SetChecked(cfg.DualAdapterMode)
+		dualAdapterCheck.OnChanged(cfg.DualAdapterMode)
+		advanced.set(cfg)
+		messageLabel.SetText("")
+	}
+
+	reloadSettings = func() {
+		fill()
+		loadAdapters()
+		loadProfiles()
+		scan.start()
+		store := settingsHistory
+		go func() {
+			historyLabel.SetText(describeHistory(store))
+		}()
+	}
+	reloadSettings()
+
+	mainWindow.SetContent(content)
+	mainWindow.SetCloseIntercept(hideSettings)
+
 	mainWindow.Show()
 }
 
-// UpdateStatus updates the connection status in the settings window if open
-func UpdateStatus(connected bool, ssid string) {
-	// This could be expanded to update the UI in real-time
+// hideSettings hides the settings window, cancelling any loads in progress
+func hideSettings() {
+	cancelLoads()
+	mainWindow.Hide()
+}
+
+// describeHistory summarizes the uptime statistics of the last week
+func describeHistory(store *history.Store) string {
+	if store == nil {
+		return "Connection history unavailable"
+	}
+	entries, err := store.Load(time.Time{})
+	if err != nil {
+		slog.Warn("Could not load connection history", "error", err)
+		return "Connection history unavailable"
+	}
+	if len(entries) == 0 {
+		return "No connection history recorded yet"
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	today := history.Compute(entries, midnight, now)
+	week := history.Compute(entries, now.AddDate(0, 0, -7), now)
+
+	text := fmt.Sprintf("Today: %.1f%% uptime\nLast 7 days: %.1f%% uptime, %d drops", today.Uptime(), week.Uptime(), week.Drops)
+	if week.Drops > 0 {
+		text += fmt.Sprintf("\nMean time between drops: %s", week.MeanTimeBetweenDrops.Round(time.Second))
+	}
+	if len(week.Reconnects) > 0 {
+		text += fmt.Sprintf("\nMean reconnect time: %s", week.MeanReconnect.Round(time.Second))
+	}
+	return text
 }
 
 // RunApp runs the Fyne event loop (blocking)
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Status is the connection state shown in the settings window
+type Status struct {
+	State   string // connected, searching or disconnected
+	Message string
+	SSID    string
+	Signal  int // percent
+	Paused  bool
+
+	LastError     string
+	LastErrorTime time.Time
+
+	// NextRetry is when the next connection attempt is due, or zero if
+	// none is planned
+	NextRetry time.Time
+}
+
+var (
+	statusMutex  sync.Mutex
+	latestStatus *Status
+	shownStatus  *statusCard
+)
+
+// UpdateStatus shows s in the settings window if it is open, and when it
+// is next opened
+func UpdateStatus(s Status) {
+	statusMutex.Lock()
+	latestStatus = &s
+	card := shownStatus
+	statusMutex.Unlock()
+
+	if card != nil {
+		card.show(s)
+	}
+}
+
+// statusCard is the status section of the settings window
+type statusCard struct {
+	content fyne.CanvasObject
+	icon    *canvas.Circle
+	message *widget.Label
+	details *widget.Label
+	err     *widget.Label
+	retry   *widget.Label
+}
+
+// newStatusCard creates the status card and registers it for updates
+func newStatusCard() *statusCard {
+	c := &statusCard{
+		icon:    canvas.NewCircle(color.NRGBA{R: 100, G: 100, B: 100, A: 255}),
+		message: widget.NewLabel("Checking connection status..."),
+		details: widget.NewLabel(""),
+		err:     widget.NewLabel(""),
+		retry:   widget.NewLabel(""),
+	}
+	c.err.Wrapping = fyne.TextWrapWord
+	c.err.TextStyle = fyne.TextStyle{Italic: true}
+
+	// The circle takes the size of its container, so pin it to a small box
+	dot := container.NewGridWrap(fyne.NewSize(12, 12), c.icon)
+	c.content = container.NewVBox(
+		container.NewHBox(container.NewCenter(dot), c.message),
+		c.details,
+		c.err,
+		c.retry,
+	)
+
+	statusMutex.Lock()
+	shownStatus = c
+	latest := latestStatus
+	statusMutex.Unlock()
+
+	if latest != nil {
+		c.show(*latest)
+	}
+	return c
+}
+
+// show updates the card for s
+func (c *statusCard) show(s Status) {
+	switch s.State {
+	case "connected":
+		c.icon.FillColor = color.NRGBA{R: 0x00, G: 0xC8, B: 0x00, A: 0xFF}
+	case "searching":
+		c.icon.FillColor = color.NRGBA{R: 0xFF, G: 0xC8, B: 0x00, A: 0xFF}
+	default:
+		c.icon.FillColor = color.NRGBA{R: 0xE0, G: 0x00, B: 0x00, A: 0xFF}
+	}
+	c.icon.Refresh()
+	c.message.SetText(s.Message)
+
+	switch {
+	case s.State == "connected":
+		c.details.SetText(fmt.Sprintf("%s, signal %d%%", s.SSID, s.Signal))
+	case s.Paused:
+		c.details.SetText("Auto-connect is paused")
+	default:
+		c.details.SetText("")
+	}
+
+	if s.LastError != "" {
+		c.err.SetText(fmt.Sprintf("Last error at %s: %s", s.LastErrorTime.Format("15:04:05"), s.LastError))
+	} else {
+		c.err.SetText("")
+	}
+
+	if !s.NextRetry.IsZero() {
+		c.retry.SetText("Next attempt at " + s.NextRetry.Format("15:04:05"))
+	} else {
+		c.retry.SetText("")
+	}
+}
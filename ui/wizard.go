@@ -0,0 +1,578 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/whenry/quadmax-wifi-connector/autostart"
+	"github.com/whenry/quadmax-wifi-connector/config"
+	"github.com/whenry/quadmax-wifi-connector/wifi"
+)
+
+// connectTimeout is how long the wizard waits for a test connection
+const connectTimeout = 20 * time.Second
+
+var wizardWindow fyne.Window
+
+var (
+	errNoAdapter     = errors.New("choose a network adapter")
+	errNoNetwork     = errors.New("choose the launch monitor's network")
+	errShortPassword = errors.New("the password must be 8 to 63 characters")
+)
+
+// wizardStep is a page of the setup wizard. enter runs when the page is
+// shown and leave checks it before moving on.
+type wizardStep struct {
+	title   string
+	content fyne.CanvasObject
+	enter   func()
+	leave   func() error
+}
+
+// wizard walks through choosing the adapter and network, testing the
+// connection and autostart
+type wizard struct {
+	cfg     *config.Config
+	onSave  func(*config.Config)
+	onClose func()
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	steps   []wizardStep
+	step    int
+	title   *widget.Label
+	body    *fyne.Container
+	message *widget.Label
+	backBtn *widget.Button
+	nextBtn *widget.Button
+
+	// Choices made so far
+	adapter  string
+	network  wifi.NetworkDetail
+	saved    bool
+	password string
+}
+
+// ShowWizard shows the first-run setup wizard. onSave gets the config when
+// setup is finished and onClose is called when the window closes. It
+// returns false if the wizard was already open.
+func ShowWizard(cfg *config.Config, onSave func(*config.Config), onClose func()) bool {
+	if wizardWindow != nil {
+		wizardWindow.Show()
+		wizardWindow.RequestFocus()
+		return false
+	}
+
+	w := &wizard{
+		cfg:     cfg,
+		onSave:  onSave,
+		onClose: onClose,
+		adapter: cfg.SelectedAdapter,
+		title:   widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		body:    container.NewStack(),
+		message: widget.NewLabel(""),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.message.Wrapping = fyne.TextWrapWord
+	w.message.Importance = widget.DangerImportance
+	w.steps = []wizardStep{
+		w.welcomeStep(),
+		w.adapterStep(),
+		w.networkStep(),
+		w.testStep(),
+		w.finishStep(),
+	}
+
+	wizardWindow = fyneApp.NewWindow("Quadmax WiFi Connector Setup")
+	wizardWindow.Resize(fyne.NewSize(520, 500))
+	wizardWindow.CenterOnScreen()
+
+	skipBtn := widget.NewButton("Skip Setup", w.skip)
+	w.backBtn = widget.NewButton("Back", func() { w.show(w.step - 1) })
+	w.nextBtn = widget.NewButton("Next", w.next)
+	w.nextBtn.Importance = widget.HighImportance
+
+	content := container.NewBorder(
+		createHeader(),
+		container.NewPadded(container.NewVBox(
+			widget.NewSeparator(),
+			w.message,
+			container.NewHBox(skipBtn, layout.NewSpacer(), w.backBtn, w.nextBtn),
+		)),
+		nil,
+		nil,
+		container.NewPadded(container.NewBorder(
+			container.NewVBox(w.title, widget.NewSeparator()),
+			nil,
+			nil,
+			nil,
+			w.body,
+		)),
+	)
+
+	wizardWindow.SetContent(content)
+	wizardWindow.SetCloseIntercept(w.close)
+	w.show(0)
+	wizardWindow.Show()
+	return true
+}
+
+// show switches to step i
+func (w *wizard) show(i int) {
+	w.step = i
+	step := w.steps[i]
+	w.title.SetText(fmt.Sprintf("Step %d of %d: %s", i+1, len(w.steps), step.title))
+	w.body.Objects = []fyne.CanvasObject{step.content}
+	w.body.Refresh()
+	w.message.SetText("")
+
+	if i == 0 {
+		w.backBtn.Disable()
+	} else {
+		w.backBtn.Enable()
+	}
+	if i == len(w.steps)-1 {
+		w.nextBtn.SetText("Finish")
+	} else {
+		w.nextBtn.SetText("Next")
+	}
+	if step.enter != nil {
+		step.enter()
+	}
+}
+
+func (w *wizard) next() {
+	if leave := w.steps[w.step].leave; leave != nil {
+		if err := leave(); err != nil {
+			w.message.SetText(err.Error())
+			return
+		}
+	}
+	if w.step < len(w.steps)-1 {
+		w.show(w.step + 1)
+	}
+}
+
+// skip closes the wizard, saving the config as it is so the wizard is not
+// shown again on the next launch
+func (w *wizard) skip() {
+	if !config.Exists() {
+		if err := w.cfg.Save(); err != nil {
+			slog.Error("Could not save settings", "error", err)
+		}
+	}
+	slog.Info("Setup wizard skipped")
+	w.close()
+}
+
+func (w *wizard) close() {
+	w.cancel()
+	wizardWindow.Close()
+	wizardWindow = nil
+	if w.onClose != nil {
+		w.onClose()
+	}
+}
+
+func (w *wizard) welcomeStep() wizardStep {
+	text := widget.NewLabel("This app keeps your PC connected to your Quadmax launch monitor's WiFi network.\n\n" +
+		"Setup finds your wireless adapter and the launch monitor's network, saves its password if needed, " +
+		"and checks that the launch monitor can be reached.\n\n" +
+		"Turn on the launch monitor before you continue. You can skip setup and run it again later from the tray menu.")
+	text.Wrapping = fyne.TextWrapWord
+	return wizardStep{title: "Welcome", content: text}
+}
+
+func (w *wizard) adapterStep() wizardStep {
+	adapterSelect := widget.NewSelect(nil, func(name string) {
+		w.adapter = name
+	})
+	adapterSelect.PlaceHolder = "Select a network adapter..."
+	load := newLoadIndicator()
+	help := widget.NewLabel("Choose the wireless adapter that connects to the launch monitor. " +
+		"If you have two, the other one can keep your internet connection, see Settings.")
+	help.Wrapping = fyne.TextWrapWord
+
+	return wizardStep{
+		title:   "Network Adapter",
+		content: container.NewVBox(adapterSelect, load.content, help),
+		enter: func() {
+			ctx := w.ctx
+			load.start()
+			adapterSelect.Disable()
+			go func() {
+				adapters, err := wifi.GetAdaptersContext(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				load.finish("adapters", err)
+				adapterSelect.Enable()
+				if err != nil {
+					slog.Warn("Could not list adapters", "error", err)
+					return
+				}
+
+				names := []string{}
+				for _, a := range adapters {
+					names = append(names, a.Name)
+				}
+				if len(names) == 0 {
+					load.finish("adapters", errors.New("no wireless adapter found"))
+				}
+				setOptions(adapterSelect, names, w.adapter, first(names))
+			}()
+		},
+		leave: func() error {
+			if w.adapter == "" {
+				return errNoAdapter
+			}
+			return nil
+		},
+	}
+}
+
+// looksLikeQuadmax reports whether an SSID is probably a launch monitor's
+func looksLikeQuadmax(ssid string) bool {
+	return strings.Contains(strings.ToLower(ssid), "quadmax")
+}
+
+func (w *wizard) networkStep() wizardStep {
+	// networks are scanned in the background and shown is read by the
+	// list, both are guarded by mu
+	var mu sync.Mutex
+	var networks, shown []scanNetwork
+
+	// shownNetwork returns the network in row id of the list
+	shownNetwork := func(id widget.ListItemID) (scanNetwork, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if id < 0 || id >= len(shown) {
+			return scanNetwork{}, false
+		}
+		return shown[id], true
+	}
+
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("Network password")
+	passwordEntry.OnChanged = func(text string) {
+		w.password = text
+	}
+	passwordRow := container.NewVBox(
+		widget.NewLabel("There is no saved profile for this network yet. Enter its password to create one:"),
+		passwordEntry,
+	)
+	passwordRow.Hide()
+
+	hint := widget.NewLabel("")
+	hint.Wrapping = fyne.TextWrapWord
+
+	list := widget.NewList(
+		func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(shown)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			n, ok := shownNetwork(id)
+			if !ok {
+				return
+			}
+			text := fmt.Sprintf("%s  (%d%%, %s, %s)", n.SSID, n.Signal, n.Band, n.Security)
+			if n.saved {
+				text += ", saved"
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		n, ok := shownNetwork(id)
+		if !ok {
+			return
+		}
+		w.network, w.saved = n.NetworkDetail, n.saved
+		if n.saved || n.Security == "Open" {
+			passwordRow.Hide()
+		} else {
+			passwordRow.Show()
+		}
+	}
+
+	showAll := widget.NewCheck("Show all networks", nil)
+	filter := func() {
+		mu.Lock()
+		scanned := networks
+		mu.Unlock()
+
+		var matching []scanNetwork
+		for _, n := range scanned {
+			if showAll.Checked || looksLikeQuadmax(n.SSID) {
+				matching = append(matching, n)
+			}
+		}
+
+		mu.Lock()
+		shown = matching
+		mu.Unlock()
+		list.UnselectAll()
+		list.Refresh()
+		passwordRow.Hide()
+		w.network, w.saved = wifi.NetworkDetail{}, false
+		for i, n := range matching {
+			if n.SSID == w.cfg.SelectedNetwork || (w.cfg.SelectedNetwork == "" && looksLikeQuadmax(n.SSID)) {
+				list.Select(i)
+				break
+			}
+		}
+	}
+	showAll.OnChanged = func(bool) { filter() }
+
+	load := newLoadIndicator()
+	var scan func()
+	rescanBtn := widget.NewButton("Scan Again", func() { scan() })
+	scan = func() {
+		ctx := w.ctx
+		adapter := w.adapter
+		load.start()
+		rescanBtn.Disable()
+		go func() {
+			details, err := wifi.ScanNetworksDetailed(ctx, adapter)
+			var profiles []string
+			if err == nil {
+				profiles, err = wifi.GetSavedProfilesContext(ctx)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			load.finish("networks", err)
+			rescanBtn.Enable()
+			if err != nil {
+				slog.Warn("Could not scan for networks", "error", err)
+				return
+			}
+
+			var scanned []scanNetwork
+			quadmax := 0
+			for _, detail := range details {
+				scanned = append(scanned, scanNetwork{NetworkDetail: detail, saved: slices.Contains(profiles, detail.SSID)})
+				if looksLikeQuadmax(detail.SSID) {
+					quadmax++
+				}
+			}
+			slices.SortFunc(scanned, func(a, b scanNetwork) int { return cmp.Compare(b.Signal, a.Signal) })
+			mu.Lock()
+			networks = scanned
+			mu.Unlock()
+
+			if quadmax == 0 {
+				hint.SetText("No Quadmax network was found. Check that the launch monitor is on, or pick its network from all networks.")
+				showAll.SetChecked(true)
+			} else {
+				hint.SetText(fmt.Sprintf("Found %d Quadmax network(s).", quadmax))
+			}
+			filter()
+		}()
+	}
+
+	return wizardStep{
+		title: "Launch Monitor Network",
+		content: container.NewBorder(
+			container.NewVBox(
+				container.NewHBox(showAll, layout.NewSpacer(), rescanBtn),
+				load.content,
+				hint,
+			),
+			passwordRow,
+			nil,
+			nil,
+			list,
+		),
+		enter: scan,
+		leave: func() error {
+			if w.network.SSID == "" {
+				return errNoNetwork
+			}
+			if !w.saved && w.network.Security != "Open" && (len(w.password) < 8 || len(w.password) > 63) {
+				return errShortPassword
+			}
+			return nil
+		},
+	}
+}
+
+func (w *wizard) testStep() wizardStep {
+	profileLabel := widget.NewLabel("")
+	connectLabel := widget.NewLabel("")
+	reachLabel := widget.NewLabel("")
+	for _, label := range []*widget.Label{profileLabel, connectLabel, reachLabel} {
+		label.Wrapping = fyne.TextWrapWord
+	}
+	progress := widget.NewProgressBarInfinite()
+	progress.Hide()
+
+	// report shows the result of a test step on label
+	report := func(label *widget.Label, text string, err error) {
+		if err != nil {
+			label.SetText(text + ": " + err.Error())
+			label.Importance = widget.DangerImportance
+		} else {
+			label.SetText(text)
+			label.Importance = widget.SuccessImportance
+		}
+		label.Refresh()
+	}
+
+	var run func()
+	retryBtn := widget.NewButton("Test Again", func() { run() })
+	run = func() {
+		ctx := w.ctx
+		adapter, network, password := w.adapter, w.network, w.password
+		for _, label := range []*widget.Label{profileLabel, connectLabel, reachLabel} {
+			label.SetText("")
+			label.Importance = widget.MediumImportance
+		}
+		retryBtn.Disable()
+		progress.Show()
+		progress.Start()
+
+		go func() {
+			defer func() {
+				if ctx.Err() == nil {
+					progress.Stop()
+					progress.Hide()
+					retryBtn.Enable()
+				}
+			}()
+
+			if !w.saved {
+				profileLabel.SetText("Saving the network profile...")
+				err := wifi.AddProfile(adapter, network, password)
+				report(profileLabel, "Network profile saved", err)
+				if err != nil {
+					slog.Warn("Could not add profile", "network", network.SSID, "error", err)
+					return
+				}
+				w.saved = true
+			} else {
+				report(profileLabel, "Using the saved network profile", nil)
+			}
+
+			connectLabel.SetText("Connecting to " + network.SSID + "...")
+			err := testConnect(ctx, adapter, network.SSID)
+			if ctx.Err() != nil {
+				return
+			}
+			report(connectLabel, "Connected to "+network.SSID, err)
+			if err != nil {
+				slog.Warn("Setup connection test failed", "network", network.SSID, "error", err)
+				return
+			}
+
+			reachLabel.SetText("Checking that the launch monitor responds...")
+			gateway, err := wifi.CheckReachable(ctx, adapter)
+			if ctx.Err() != nil {
+				return
+			}
+			report(reachLabel, "The launch monitor responds at "+gateway, err)
+		}()
+	}
+
+	help := widget.NewLabel("You can continue if a check fails, the app keeps trying to connect in the background.")
+	help.Wrapping = fyne.TextWrapWord
+
+	return wizardStep{
+		title: "Connection Test",
+		content: container.NewVBox(
+			profileLabel,
+			connectLabel,
+			reachLabel,
+			progress,
+			container.NewHBox(layout.NewSpacer(), retryBtn),
+			help,
+		),
+		enter: run,
+	}
+}
+
+// testConnect connects the adapter to ssid and waits until it is connected.
+// Cancelling ctx stops the connect command too.
+func testConnect(ctx context.Context, adapter, ssid string) error {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if err := wifi.ConnectContext(ctx, adapter, ssid); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for the connection")
+		case <-ticker.C:
+			status, err := wifi.GetConnectionStatus(adapter)
+			if err == nil && status.Connected && status.SSID == ssid {
+				return nil
+			}
+		}
+	}
+}
+
+func (w *wizard) finishStep() wizardStep {
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+
+	autostartCheck := widget.NewCheck("Start Quadmax WiFi Connector when I sign in", nil)
+	// Autostart is suggested on the first run and left as it is on later
+	// runs
+	autostartCheck.SetChecked(true)
+	if config.Exists() {
+		enabled, err := autostart.Enabled()
+		autostartCheck.SetChecked(err == nil && enabled)
+	}
+
+	return wizardStep{
+		title:   "Finish",
+		content: container.NewVBox(summary, autostartCheck),
+		enter: func() {
+			summary.SetText(fmt.Sprintf("The app will keep adapter %q connected to %q.\n\n"+
+				"More options, such as reconnecting to your home network when the launch monitor is off, are in Settings.",
+				w.adapter, w.network.SSID))
+		},
+		leave: func() error {
+			w.cfg.SelectedAdapter = w.adapter
+			w.cfg.SelectedNetwork = w.network.SSID
+			if err := w.cfg.Save(); err != nil {
+				slog.Error("Could not save settings", "error", err)
+				return err
+			}
+
+			setAutostart := autostart.Disable
+			if autostartCheck.Checked {
+				setAutostart = autostart.Enable
+			}
+			if err := setAutostart(); err != nil {
+				slog.Warn("Could not change autostart", "error", err)
+			}
+
+			slog.Info("Setup finished", "adapter", w.adapter, "network", w.network.SSID)
+			if w.onSave != nil {
+				w.onSave(w.cfg)
+			}
+			w.close()
+			return nil
+		},
+	}
+}
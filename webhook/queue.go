@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/whenry/quadmax-wifi-connector/config"
+)
+
+const (
+	queueFile = "webhook_queue.json"
+
+	// Deliveries are retried with exponential backoff between minBackoff and
+	// maxBackoff and given up after maxAge. The queue keeps at most
+	// maxQueue deliveries, dropping the oldest.
+	minBackoff = 5 * time.Second
+	maxBackoff = 10 * time.Minute
+	maxAge     = 24 * time.Hour
+	maxQueue   = 500
+)
+
+// delivery is an event waiting to be posted to a target. Deliveries are
+// kept on disk so that events from offline periods are sent later.
+type delivery struct {
+	EventID  string          `json:"event_id"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+	Created  time.Time       `json:"created"`
+	Attempts int             `json:"attempts"`
+	Next     time.Time       `json:"next"`
+}
+
+// Dispatcher posts events to the webhook targets in the background
+type Dispatcher struct {
+	path  string
+	wake  chan struct{}
+	stop  chan struct{}
+	start sync.Once
+
+	mu      sync.Mutex
+	targets []Target
+	queue   []delivery
+}
+
+// DefaultPath returns the path of the queue file in the config directory
+func DefaultPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, queueFile), nil
+}
+
+// Open returns a dispatcher that keeps its queue in the file at path.
+// Deliveries left from the last run resume once the targets are set.
+func Open(path string) *Dispatcher {
+	d := &Dispatcher{
+		path: path,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &d.queue); err != nil {
+			slog.Warn("Could not read webhook queue", "path", path, "error", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Could not read webhook queue", "path", path, "error", err)
+	}
+	return d
+}
+
+// Close stops delivering events. Queued events are delivered by the next
+// dispatcher opened on the same file.
+func (d *Dispatcher) Close() {
+	close(d.stop)
+}
+
+// SetTargets replaces the targets, starting delivery on the first call.
+// Queued deliveries to removed targets are dropped when they come up.
+func (d *Dispatcher) SetTargets(targets []Target) {
+	d.mu.Lock()
+	d.targets = append([]Target(nil), targets...)
+	d.mu.Unlock()
+	d.start.Do(func() { go d.run() })
+	d.notify()
+}
+
+// Send queues e for every target
+func (d *Dispatcher) Send(e Event) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		slog.Warn("Could not encode webhook event", "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	if len(d.targets) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	for _, target := range d.targets {
+		d.queue = append(d.queue, delivery{EventID: e.ID, URL: target.URL, Body: body, Created: now, Next: now})
+	}
+	if len(d.queue) > maxQueue {
+		slog.Warn("Webhook queue full, dropping oldest events", "dropped", len(d.queue)-maxQueue)
+		d.queue = append([]delivery(nil), d.queue[len(d.queue)-maxQueue:]...)
+	}
+	d.save()
+	d.mu.Unlock()
+	d.notify()
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers due events in order until the dispatcher is closed
+func (d *Dispatcher) run() {
+	for {
+		item, target, wait := d.next()
+		if wait == 0 {
+			err := post(target, item.Body)
+			d.finish(item, err)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-d.wake:
+			timer.Stop()
+		case <-d.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// next returns the first due delivery and its target, or how long to wait
+// for one
+func (d *Dispatcher) next() (delivery, Target, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Hour
+	// Events go to each target in order, so a retry holds back the events
+	// queued after it for the same target
+	blocked := map[string]bool{}
+	for i := 0; i < len(d.queue); i++ {
+		item := d.queue[i]
+		target, ok := d.target(item.URL)
+		if !ok || now.Sub(item.Created) > maxAge {
+			slog.Warn("Dropping webhook event", "event", item.EventID, "url", redactURL(item.URL), "attempts", item.Attempts)
+			d.queue = append(d.queue[:i], d.queue[i+1:]...)
+			d.save()
+			i--
+			continue
+		}
+		if blocked[item.URL] {
+			continue
+		}
+		if !item.Next.After(now) {
+			return item, target, 0
+		}
+		blocked[item.URL] = true
+		wait = min(wait, item.Next.Sub(now))
+	}
+	return delivery{}, Target{}, wait
+}
+
+// finish removes a delivered item from the queue or schedules a retry
+func (d *Dispatcher) finish(item delivery, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := d.index(item)
+	if i < 0 {
+		return
+	}
+
+	var permanent permanentError
+	switch {
+	case err == nil:
+		slog.Debug("Delivered webhook event", "event", item.EventID, "url", redactURL(item.URL))
+		d.queue = append(d.queue[:i], d.queue[i+1:]...)
+	case errors.As(err, &permanent):
+		slog.Warn("Webhook rejected event", "event", item.EventID, "url", redactURL(item.URL), "error", err)
+		d.queue = append(d.queue[:i], d.queue[i+1:]...)
+	default:
+		attempts := d.queue[i].Attempts + 1
+		backoff := min(minBackoff<<min(attempts-1, 16), maxBackoff)
+		d.queue[i].Attempts = attempts
+		d.queue[i].Next = time.Now().Add(backoff)
+		slog.Warn("Could not deliver webhook event", "event", item.EventID, "url", redactURL(item.URL), "attempts", attempts, "retry_in", backoff, "error", err)
+	}
+	d.save()
+}
+
+func (d *Dispatcher) target(url string) (Target, bool) {
+	for _, target := range d.targets {
+		if target.URL == url {
+			return target, true
+		}
+	}
+	return Target{}, false
+}
+
+func (d *Dispatcher) index(item delivery) int {
+	for i, queued := range d.queue {
+		if queued.EventID == item.EventID && queued.URL == item.URL {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the queue to disk, the caller must hold d.mu
+func (d *Dispatcher) save() {
+	data, err := json.Marshal(d.queue)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(d.path), 0755)
+	}
+	if err == nil {
+		tmp := d.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, d.path)
+		}
+	}
+	if err != nil {
+		slog.Warn("Could not save webhook queue", "path", d.path, "error", err)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, keyed with
+// the target's secret, as "sha256=<hex>"
+const SignatureHeader = "X-Quadmax-Signature"
+
+// Target is a URL that receives events
+type Target struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Event is the JSON payload posted to targets. Text is a readable summary
+// so that chat webhooks such as Slack and Teams can show it as is.
+type Event struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	Host          string    `json:"host"`
+	State         string    `json:"state"`
+	PreviousState string    `json:"previous_state"`
+	Reason        string    `json:"reason,omitempty"`
+	SSID          string    `json:"ssid,omitempty"`
+	Adapter       string    `json:"adapter,omitempty"`
+	Signal        int       `json:"signal,omitempty"` // percent
+	Text          string    `json:"text"`
+}
+
+// Sign returns the signature header value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for body, for receivers
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// permanentError is a failure that retrying will not fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// post delivers body to target
+func post(target Target, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quadmax-wifi-connector")
+	if target.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(target.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return permanentError{fmt.Errorf("rejected with status %d", resp.StatusCode)}
+	default:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+// redactURL drops the path and query of a URL for logging, as chat webhook
+// URLs carry their credentials there
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a webhook endpoint that checks signatures, answers with
+// status and records the events it accepts
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mu      sync.Mutex
+	status  int
+	events  []Event
+	badSigs int
+	calls   int
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{secret: secret, status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls++
+		if r.secret != "" && !Verify(r.secret, body, req.Header.Get(SignatureHeader)) {
+			r.badSigs++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.status != http.StatusOK {
+			w.WriteHeader(r.status)
+			return
+		}
+		var e Event
+		json.Unmarshal(body, &e)
+		r.events = append(r.events, e)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+}
+
+func (r *receiver) received() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// queued returns a copy of the dispatcher's queue
+func queued(d *Dispatcher) []delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]delivery(nil), d.queue...)
+}
+
+// retryNow makes every queued delivery due immediately
+func retryNow(d *Dispatcher) {
+	d.mu.Lock()
+	for i := range d.queue {
+		d.queue[i].Next = time.Now()
+	}
+	d.mu.Unlock()
+	d.notify()
+}
+
+// eventually waits up to a few seconds for cond
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"state":"connected"}`)
+	sig := Sign("secret", body)
+	if !Verify("secret", body, sig) {
+		t.Error("Verify rejected its own signature")
+	}
+	if Verify("other", body, sig) || Verify("secret", []byte(`{}`), sig) {
+		t.Error("Verify accepted a wrong secret or body")
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	recv := newReceiver(t, "s3cret")
+	d := Open(filepath.Join(t.TempDir(), queueFile))
+	defer d.Close()
+	d.SetTargets([]Target{{URL: recv.URL, Secret: "s3cret"}})
+
+	d.Send(Event{State: "connected", PreviousState: "searching", Text: "connected"})
+	d.Send(Event{State: "disconnected", PreviousState: "connected", Text: "disconnected"})
+
+	eventually(t, "both events", func() bool { return len(recv.received()) == 2 })
+	events := recv.received()
+	if events[0].State != "connected" || events[1].State != "disconnected" || events[0].ID == "" {
+		t.Errorf("received %+v", events)
+	}
+	if recv.badSigs != 0 {
+		t.Errorf("%d requests had a bad signature", recv.badSigs)
+	}
+	eventually(t, "an empty queue", func() bool { return len(queued(d)) == 0 })
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	recv := newReceiver(t, "")
+	recv.setStatus(http.StatusServiceUnavailable)
+	d := Open(filepath.Join(t.TempDir(), queueFile))
+	defer d.Close()
+	d.SetTargets([]Target{{URL: recv.URL}})
+
+	start := time.Now()
+	d.Send(Event{State: "connected"})
+	eventually(t, "the first attempt", func() bool {
+		q := queued(d)
+		return len(q) == 1 && q[0].Attempts == 1
+	})
+	if next := queued(d)[0].Next.Sub(start); next < minBackoff || next > minBackoff+time.Second {
+		t.Errorf("first retry in %v, want about %v", next, minBackoff)
+	}
+
+	retryNow(d)
+	eventually(t, "the second attempt", func() bool { return queued(d)[0].Attempts == 2 })
+	if next := time.Until(queued(d)[0].Next); next < 2*minBackoff-time.Second || next > 2*minBackoff {
+		t.Errorf("second retry in %v, want about %v", next, 2*minBackoff)
+	}
+
+	recv.setStatus(http.StatusOK)
+	retryNow(d)
+	eventually(t, "the delivery", func() bool { return len(recv.received()) == 1 })
+	eventually(t, "an empty queue", func() bool { return len(queued(d)) == 0 })
+}
+
+func TestRejectedEventIsDropped(t *testing.T) {
+	recv := newReceiver(t, "")
+	recv.setStatus(http.StatusBadRequest)
+	d := Open(filepath.Join(t.TempDir(), queueFile))
+	defer d.Close()
+	d.SetTargets([]Target{{URL: recv.URL}})
+
+	d.Send(Event{State: "connected"})
+	eventually(t, "the rejection", func() bool {
+		recv.mu.Lock()
+		defer recv.mu.Unlock()
+		return recv.calls == 1
+	})
+	eventually(t, "an empty queue", func() bool { return len(queued(d)) == 0 })
+}
+
+func TestQueueSurvivesReopen(t *testing.T) {
+	recv := newReceiver(t, "")
+	recv.setStatus(http.StatusServiceUnavailable)
+	path := filepath.Join(t.TempDir(), queueFile)
+	targets := []Target{{URL: recv.URL}}
+
+	d := Open(path)
+	d.SetTargets(targets)
+	d.Send(Event{State: "disconnected"})
+	eventually(t, "the failed attempt", func() bool { return queued(d)[0].Attempts == 1 })
+	d.Close()
+
+	// The queue is kept until the targets are known
+	d = Open(path)
+	defer d.Close()
+	time.Sleep(50 * time.Millisecond)
+	if q := queued(d); len(q) != 1 || q[0].URL != recv.URL || q[0].Attempts != 1 {
+		t.Fatalf("reopened queue = %+v", q)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []delivery
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 1 {
+		t.Fatalf("queue file holds %s, %v", data, err)
+	}
+
+	recv.setStatus(http.StatusOK)
+	retryNow(d)
+	d.SetTargets(targets)
+	eventually(t, "the delivery", func() bool { return len(recv.received()) == 1 })
+	if got := recv.received()[0].State; got != "disconnected" {
+		t.Errorf("delivered state %q", got)
+	}
+}
+
+func TestRemovedTargetIsDropped(t *testing.T) {
+	recv := newReceiver(t, "")
+	recv.setStatus(http.StatusServiceUnavailable)
+	path := filepath.Join(t.TempDir(), queueFile)
+
+	d := Open(path)
+	d.SetTargets([]Target{{URL: recv.URL}})
+	d.Send(Event{State: "connected"})
+	eventually(t, "the failed attempt", func() bool { return queued(d)[0].Attempts == 1 })
+	d.Close()
+
+	d = Open(path)
+	defer d.Close()
+	d.SetTargets(nil)
+	eventually(t, "an empty queue", func() bool { return len(queued(d)) == 0 })
+}
+
+func TestRedactURL(t *testing.T) {
+	if got := redactURL("https://hooks.slack.com/services/T000/B000/XXXX?x=1"); got != "https://hooks.slack.com" {
+		t.Errorf("redactURL = %q", got)
+	}
+	if got := redactURL("not a url"); got != "(invalid URL)" {
+		t.Errorf("redactURL of an invalid URL = %q", got)
+	}
+}
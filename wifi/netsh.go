@@ -0,0 +1,91 @@
+package wifi
+
+import (
+	"errors"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandObserver is told about every netsh command that runs
+var commandObserver func(command string, duration time.Duration, err error)
+
+// SetCommandObserver registers a function called after each netsh command
+// with a short command name such as "show_interfaces" or "connect"
+func SetCommandObserver(observer func(command string, duration time.Duration, err error)) {
+	commandObserver = observer
+}
+
+// commandName returns a short name for a netsh command line
+func commandName(args []string) string {
+	// Skip "netsh wlan" and any name=value arguments
+	var words []string
+	for _, arg := range args[min(2, len(args)):] {
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, "_")
+}
+
+// runNetsh runs a netsh command and returns its output, logging the
+// invocation, duration and exit status at debug level
+func runNetsh(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.Output()
+	duration := time.Since(start)
+
+	attrs := []any{
+		"args", strings.Join(cmd.Args[1:], " "),
+		"duration", duration,
+	}
+	if err != nil {
+		exitCode := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		attrs = append(attrs, "exit_code", exitCode, "error", err)
+	} else {
+		attrs = append(attrs, "exit_code", 0)
+	}
+
+	slog.Debug("netsh", attrs...)
+	if commandObserver != nil {
+		commandObserver(commandName(cmd.Args), duration, err)
+	}
+	return output, err
+}
+
+// QueryOutput is the raw output of a netsh query
+type QueryOutput struct {
+	Name   string
+	Args   []string
+	Output []byte
+	Err    error
+}
+
+// DiagnosticQueries runs every netsh query this package uses, plus driver
+// and profile details, and returns their raw output
+func DiagnosticQueries(adapterName, profileName string) []QueryOutput {
+	queries := []QueryOutput{
+		{Name: "interfaces", Args: []string{"wlan", "show", "interfaces"}},
+		{Name: "networks", Args: []string{"wlan", "show", "networks", "mode=bssid"}},
+		{Name: "profiles", Args: []string{"wlan", "show", "profiles"}},
+		{Name: "drivers", Args: []string{"wlan", "show", "drivers"}},
+		{Name: "settings", Args: []string{"wlan", "show", "settings"}},
+	}
+	if adapterName != "" {
+		queries[1].Args = append(queries[1].Args, "interface="+adapterName)
+	}
+	if profileName != "" {
+		queries = append(queries, QueryOutput{Name: "profile", Args: []string{"wlan", "show", "profile", "name=" + profileName}})
+	}
+
+	for i := range queries {
+		queries[i].Output, queries[i].Err = runNetsh(exec.Command("netsh", queries[i].Args...))
+	}
+	return queries
+}
@@ -0,0 +1,114 @@
+package wifi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"text/template"
+)
+
+// profileAuth maps the authentication netsh reports for a network to the
+// one used in a profile
+var profileAuth = map[string]string{
+	"Open":          "open",
+	"WPA-Personal":  "WPAPSK",
+	"WPA2-Personal": "WPA2PSK",
+	"WPA3-Personal": "WPA3SAE",
+}
+
+// profileEncryption maps the encryption netsh reports for a network to the
+// one used in a profile
+var profileEncryption = map[string]string{
+	"None": "none",
+	"CCMP": "AES",
+	"GCMP": "AES",
+	"TKIP": "TKIP",
+}
+
+var profileTemplate = template.Must(template.New("profile").Funcs(template.FuncMap{
+	"xml": func(s string) (string, error) {
+		var b bytes.Buffer
+		err := xml.EscapeText(&b, []byte(s))
+		return b.String(), err
+	},
+}).Parse(`<?xml version="1.0"?>
+<WLANProfile xmlns="http://www.microsoft.com/networking/WLAN/profile/v1">
+	<name>{{xml .SSID}}</name>
+	<SSIDConfig>
+		<SSID>
+			<name>{{xml .SSID}}</name>
+		</SSID>
+	</SSIDConfig>
+	<connectionType>ESS</connectionType>
+	<connectionMode>manual</connectionMode>
+	<MSM>
+		<security>
+			<authEncryption>
+				<authentication>{{.Auth}}</authentication>
+				<encryption>{{.Encryption}}</encryption>
+				<useOneX>false</useOneX>
+			</authEncryption>
+			{{- if .Password}}
+			<sharedKey>
+				<keyType>passPhrase</keyType>
+				<protected>false</protected>
+				<keyMaterial>{{xml .Password}}</keyMaterial>
+			</sharedKey>
+			{{- end}}
+		</security>
+	</MSM>
+</WLANProfile>
+`))
+
+// AddProfile saves a profile for a visible network so Connect can use it.
+// The profile is set to connect manually, leaving that to this app.
+func AddProfile(adapterName string, network NetworkDetail, password string) error {
+	auth, ok := profileAuth[network.Security]
+	if !ok {
+		return fmt.Errorf("%s networks must be added in Windows", network.Security)
+	}
+	encryption, ok := profileEncryption[network.Encryption]
+	if !ok {
+		encryption = "AES"
+	}
+	if auth == "open" {
+		encryption, password = "none", ""
+	} else if len(password) < 8 || len(password) > 63 {
+		return fmt.Errorf("the password must be 8 to 63 characters")
+	}
+
+	var profile bytes.Buffer
+	err := profileTemplate.Execute(&profile, map[string]string{
+		"SSID":       network.SSID,
+		"Auth":       auth,
+		"Encryption": encryption,
+		"Password":   password,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The file holds the password in the clear, so it only lives as long
+	// as netsh needs it
+	f, err := os.CreateTemp("", "quadmax-profile-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(profile.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	args := []string{"wlan", "add", "profile", "filename=" + f.Name(), "user=current"}
+	if adapterName != "" {
+		args = append(args, "interface="+adapterName)
+	}
+	_, err = runNetsh(exec.Command("netsh", args...))
+	return err
+}
@@ -0,0 +1,45 @@
+package wifi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// errNoGateway is returned when an adapter has no default gateway to ping
+var errNoGateway = errors.New("the adapter has no default gateway")
+
+// Gateway returns the default gateway of an adapter. Connected to a launch
+// monitor's access point, this is the device itself.
+func Gateway(ctx context.Context, adapterName string) (string, error) {
+	cmd := exec.CommandContext(ctx, "netsh", "interface", "ip", "show", "config", "name="+adapterName)
+	output, err := runNetsh(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, ok := parseField(strings.TrimSpace(scanner.Text()))
+		if ok && key == "Default Gateway" && net.ParseIP(value) != nil {
+			return value, nil
+		}
+	}
+	return "", errNoGateway
+}
+
+// CheckReachable pings the default gateway of an adapter and returns its
+// address
+func CheckReachable(ctx context.Context, adapterName string) (string, error) {
+	gateway, err := Gateway(ctx, adapterName)
+	if err != nil {
+		return "", err
+	}
+	if err := exec.CommandContext(ctx, "ping", "-n", "2", "-w", "2000", gateway).Run(); err != nil {
+		return gateway, errors.New("no reply from " + gateway)
+	}
+	return gateway, nil
+}
@@ -0,0 +1,116 @@
+package wifi
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// NetworkDetail describes a visible network through its strongest access
+// point
+type NetworkDetail struct {
+	SSID         string `json:"ssid"`
+	Security     string `json:"security"`
+	Encryption   string `json:"encryption"`
+	BSSID        string `json:"bssid"`
+	Signal       int    `json:"signal"` // percent
+	Radio        string `json:"radio"`
+	Band         string `json:"band"`
+	Channel      int    `json:"channel"`
+	AccessPoints int    `json:"access_points"`
+}
+
+// ScanNetworksDetailed lists the visible networks on an adapter with signal,
+// band, channel and security
+func ScanNetworksDetailed(ctx context.Context, adapterName string) ([]NetworkDetail, error) {
+	args := []string{"wlan", "show", "networks", "mode=bssid"}
+	if adapterName != "" {
+		args = append(args, "interface="+adapterName)
+	}
+	output, err := runNetsh(exec.CommandContext(ctx, "netsh", args...))
+	if err != nil {
+		return nil, err
+	}
+	return parseNetworkDetails(string(output)), nil
+}
+
+// parseNetworkDetails parses "netsh wlan show networks mode=bssid"
+func parseNetworkDetails(output string) []NetworkDetail {
+	var networks []NetworkDetail
+	var network *NetworkDetail
+	var ap NetworkDetail // the access point being read
+
+	// endAP keeps ap as the network's details if it is the strongest
+	endAP := func() {
+		if network == nil || ap.BSSID == "" {
+			return
+		}
+		network.AccessPoints++
+		if network.AccessPoints == 1 || ap.Signal > network.Signal {
+			network.BSSID = ap.BSSID
+			network.Signal = ap.Signal
+			network.Radio = ap.Radio
+			network.Band = ap.Band
+			network.Channel = ap.Channel
+		}
+		ap = NetworkDetail{}
+	}
+	endNetwork := func() {
+		endAP()
+		if network != nil && network.SSID != "" {
+			if network.Band == "" {
+				network.Band = bandForChannel(network.Channel)
+			}
+			networks = append(networks, *network)
+		}
+		network = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := parseField(strings.TrimSpace(scanner.Text()))
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(key, "SSID"):
+			endNetwork()
+			network = &NetworkDetail{SSID: value}
+		case network == nil:
+			continue
+		case key == "Authentication":
+			network.Security = value
+		case key == "Encryption":
+			network.Encryption = value
+		case strings.HasPrefix(key, "BSSID"):
+			endAP()
+			ap.BSSID = value
+		case key == "Signal":
+			ap.Signal, _ = strconv.Atoi(strings.TrimSuffix(value, "%"))
+		case key == "Radio type":
+			ap.Radio = value
+		case key == "Band":
+			ap.Band = value
+		case key == "Channel":
+			ap.Channel, _ = strconv.Atoi(value)
+		}
+	}
+	endNetwork()
+
+	return networks
+}
+
+// bandForChannel guesses the band for netsh versions that don't report it
+func bandForChannel(channel int) string {
+	switch {
+	case channel <= 0:
+		return ""
+	case channel <= 14:
+		return "2.4 GHz"
+	default:
+		return "5 GHz"
+	}
+}
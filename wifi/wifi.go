@@ -2,33 +2,50 @@ package wifi
 
 import (
 	"bufio"
+	"context"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 // Adapter represents a wireless network adapter
 type Adapter struct {
-	Name  string
-	State string
+	Name  string `json:"name"`
+	State string `json:"state"`
 }
 
 // Network represents a visible WiFi network
 type Network struct {
-	SSID string
+	SSID string `json:"ssid"`
 }
 
 // ConnectionStatus represents the current WiFi connection state
 type ConnectionStatus struct {
-	Connected    bool
-	SSID         string
-	AdapterName  string
-	SignalStrength string
+	Connected      bool   `json:"connected"`
+	SSID           string `json:"ssid"`
+	BSSID          string `json:"bssid"`
+	AdapterName    string `json:"adapter"`
+	SignalStrength string `json:"signal"`
+}
+
+// SignalPercent returns the signal strength as a number from 0 to 100
+func (s *ConnectionStatus) SignalPercent() int {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s.SignalStrength), "%"))
+	if err != nil {
+		return 0
+	}
+	return percent
 }
 
 // GetAdapters returns a list of wireless network adapters
 func GetAdapters() ([]Adapter, error) {
-	cmd := exec.Command("netsh", "wlan", "show", "interfaces")
-	output, err := cmd.Output()
+	return GetAdaptersContext(context.Background())
+}
+
+// GetAdaptersContext is GetAdapters, killing netsh if ctx is done first
+func GetAdaptersContext(ctx context.Context) ([]Adapter, error) {
+	cmd := exec.CommandContext(ctx, "netsh", "wlan", "show", "interfaces")
+	output, err := runNetsh(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +90,7 @@ func ScanNetworks(adapterName string) ([]Network, error) {
 		cmd = exec.Command("netsh", "wlan", "show", "networks")
 	}
 
-	output, err := cmd.Output()
+	output, err := runNetsh(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -83,15 +100,10 @@ func ScanNetworks(adapterName string) ([]Network, error) {
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
-		if strings.HasPrefix(line, "SSID") && !strings.HasPrefix(line, "SSID ") {
-			// Handle "SSID 1 : NetworkName" format
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				ssid := strings.TrimSpace(parts[1])
-				if ssid != "" {
-					networks = append(networks, Network{SSID: ssid})
-				}
-			}
+		// Handle "SSID 1 : NetworkName" format
+		key, value, ok := parseField(line)
+		if ok && strings.HasPrefix(key, "SSID") && value != "" {
+			networks = append(networks, Network{SSID: value})
 		}
 	}
 
@@ -100,8 +112,14 @@ func ScanNetworks(adapterName string) ([]Network, error) {
 
 // GetSavedProfiles returns a list of saved WiFi profiles
 func GetSavedProfiles() ([]string, error) {
-	cmd := exec.Command("netsh", "wlan", "show", "profiles")
-	output, err := cmd.Output()
+	return GetSavedProfilesContext(context.Background())
+}
+
+// GetSavedProfilesContext is GetSavedProfiles, killing netsh if ctx is done
+// first
+func GetSavedProfilesContext(ctx context.Context) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "netsh", "wlan", "show", "profiles")
+	output, err := runNetsh(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +146,7 @@ func GetSavedProfiles() ([]string, error) {
 // GetConnectionStatus returns the current WiFi connection status for an adapter
 func GetConnectionStatus(adapterName string) (*ConnectionStatus, error) {
 	cmd := exec.Command("netsh", "wlan", "show", "interfaces")
-	output, err := cmd.Output()
+	output, err := runNetsh(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -141,11 +159,17 @@ func GetConnectionStatus(adapterName string) (*ConnectionStatus, error) {
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
-		if strings.HasPrefix(line, "Name") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				currentAdapterName = strings.TrimSpace(parts[1])
-				inTargetAdapter = (adapterName == "" || currentAdapterName == adapterName)
+		key, value, ok := parseField(line)
+		if !ok {
+			continue
+		}
+
+		if key == "Name" {
+			currentAdapterName = value
+			inTargetAdapter = (adapterName == "" || currentAdapterName == adapterName)
+			if !inTargetAdapter && status.AdapterName != "" {
+				// Already read the target adapter
+				break
 			}
 		}
 
@@ -153,44 +177,60 @@ func GetConnectionStatus(adapterName string) (*ConnectionStatus, error) {
 			continue
 		}
 
-		if strings.HasPrefix(line, "State") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				state := strings.TrimSpace(parts[1])
-				status.Connected = (state == "connected")
-				status.AdapterName = currentAdapterName
-			}
-		} else if strings.HasPrefix(line, "SSID") && !strings.HasPrefix(line, "SSID ") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				status.SSID = strings.TrimSpace(parts[1])
-			}
-		} else if strings.HasPrefix(line, "Signal") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				status.SignalStrength = strings.TrimSpace(parts[1])
-			}
-		}
-
-		// If we found connection info for our target adapter, return
-		if inTargetAdapter && status.AdapterName != "" && adapterName != "" {
-			break
+		switch key {
+		case "State":
+			status.Connected = (value == "connected")
+			status.AdapterName = currentAdapterName
+		case "SSID":
+			status.SSID = value
+		case "BSSID":
+			status.BSSID = value
+		case "Signal":
+			status.SignalStrength = value
 		}
 	}
 
 	return status, nil
 }
 
+// parseField splits a "Key : Value" line of netsh output
+func parseField(line string) (key, value string, ok bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
 // Connect connects to a WiFi network using an existing Windows profile
 func Connect(adapterName, ssid string) error {
+	return ConnectContext(context.Background(), adapterName, ssid)
+}
+
+// ConnectContext is Connect, killing netsh if ctx is done first
+func ConnectContext(ctx context.Context, adapterName, ssid string) error {
+	var cmd *exec.Cmd
+	if adapterName != "" {
+		cmd = exec.CommandContext(ctx, "netsh", "wlan", "connect", "name="+ssid, "interface="+adapterName)
+	} else {
+		cmd = exec.CommandContext(ctx, "netsh", "wlan", "connect", "name="+ssid)
+	}
+
+	_, err := runNetsh(cmd)
+	return err
+}
+
+// Disconnect disconnects an adapter from its current network
+func Disconnect(adapterName string) error {
 	var cmd *exec.Cmd
 	if adapterName != "" {
-		cmd = exec.Command("netsh", "wlan", "connect", "name="+ssid, "interface="+adapterName)
+		cmd = exec.Command("netsh", "wlan", "disconnect", "interface="+adapterName)
 	} else {
-		cmd = exec.Command("netsh", "wlan", "connect", "name="+ssid)
+		cmd = exec.Command("netsh", "wlan", "disconnect")
 	}
 
-	return cmd.Run()
+	_, err := runNetsh(cmd)
+	return err
 }
 
 // IsNetworkAvailable checks if a specific SSID is in range